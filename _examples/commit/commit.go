@@ -1,6 +1,8 @@
 package commit
 
 import (
+	"fmt"
+
 	ipld "github.com/ipfs/go-ipld"
 )
 
@@ -23,11 +25,24 @@ type Commit struct {
 	Comment   String      // describes the commit
 }
 
-func (c *Commit) IPLDValidate() bool {
-	// check at least one parent exists
-	// check Parents have proper type
-	// check author exists and has proper type
-	// check commiter exists and has proper type
-	// check object exists and has proper type
-	return true
+func (c *Commit) IPLDValidate() error {
+	var verr ipld.ValidationError
+	if len(c.Parents) == 0 {
+		verr.Add("parents", "at least one parent is required")
+	}
+	for i, p := range c.Parents {
+		if !ipld.IsLink(ipld.Node(p)) {
+			verr.Add(fmt.Sprintf("parents/%d", i), "parent must be an mlink")
+		}
+	}
+	if !ipld.IsLink(ipld.Node(c.Author)) {
+		verr.Add("author", "author must be an mlink")
+	}
+	if !ipld.IsLink(ipld.Node(c.Committer)) {
+		verr.Add("committer", "committer must be an mlink")
+	}
+	if !ipld.IsLink(ipld.Node(c.Object)) {
+		verr.Add("object", "object must be an mlink")
+	}
+	return verr.Err()
 }
@@ -0,0 +1,73 @@
+package ipld
+
+import (
+	"reflect"
+)
+
+// Map is the read access to a map of the data model, implemented by Node
+// and PersistentNode. Keys are the keys stored in the data, see
+// EscapePathComponent.
+type Map interface {
+	// Len returns the number of entries.
+	Len() int
+	// Keys returns the keys, in no particular order.
+	Keys() []string
+	// Lookup returns the value at key, and whether it is present.
+	Lookup(key string) (interface{}, bool)
+}
+
+// AsMap returns v as a Node if it is a map of the data model: a Node, a
+// Link, a Map such as PersistentNode, or any other map with string keys,
+// such as map[string]Link. Nodes, Links and map[string]interface{} values
+// are returned as-is, other maps are copied.
+func AsMap(v interface{}) (Node, bool) {
+	switch vt := v.(type) {
+	case Node:
+		return vt, true
+	case Link:
+		return Node(vt), true
+	case map[string]interface{}:
+		return Node(vt), true
+	case Map:
+		n := make(Node, vt.Len())
+		for _, k := range vt.Keys() {
+			n[k], _ = vt.Lookup(k)
+		}
+		return n, true
+	case nil:
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	n := make(Node, rv.Len())
+	for _, k := range rv.MapKeys() {
+		n[k.String()] = rv.MapIndex(k).Interface()
+	}
+	return n, true
+}
+
+// AsList returns the elements of v if it is a list of the data model: a
+// slice or an array of any type, such as []Node or []int, except byte
+// slices and arrays which are bytes. []interface{} values are returned
+// as-is, other lists are copied.
+func AsList(v interface{}) ([]interface{}, bool) {
+	switch vt := v.(type) {
+	case []interface{}:
+		return vt, true
+	case []byte, nil:
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	s := make([]interface{}, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+	return s, true
+}
@@ -0,0 +1,102 @@
+package ipld
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestAsMapAsList(t *testing.T) {
+	link := Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"}
+
+	maps := []struct {
+		v        interface{}
+		expected Node
+	}{
+		{Node{"a": 1}, Node{"a": 1}},
+		{link, Node(link)},
+		{map[string]interface{}{"a": 1}, Node{"a": 1}},
+		{map[string]Link{"a": link}, Node{"a": link}},
+		{map[string]int{"a": 1}, Node{"a": 1}},
+	}
+	for _, c := range maps {
+		n, ok := AsMap(c.v)
+		if !ok || !reflect.DeepEqual(n, c.expected) {
+			t.Errorf("%#v: expected %#v, got %#v", c.v, c.expected, n)
+		}
+	}
+
+	lists := []struct {
+		v        interface{}
+		expected []interface{}
+	}{
+		{[]interface{}{1, "a"}, []interface{}{1, "a"}},
+		{[]int{1, 2}, []interface{}{1, 2}},
+		{[2]string{"a", "b"}, []interface{}{"a", "b"}},
+		{[]Node{{"a": 1}}, []interface{}{Node{"a": 1}}},
+		{[]Link{link}, []interface{}{link}},
+	}
+	for _, c := range lists {
+		s, ok := AsList(c.v)
+		if !ok || !reflect.DeepEqual(s, c.expected) {
+			t.Errorf("%#v: expected %#v, got %#v", c.v, c.expected, s)
+		}
+	}
+
+	for _, v := range []interface{}{nil, "a", 1, []byte("a"), [4]byte{}, map[int]string{}} {
+		if _, ok := AsMap(v); ok {
+			t.Errorf("%#v is not a map", v)
+		}
+		if _, ok := AsList(v); ok {
+			t.Errorf("%#v is not a list", v)
+		}
+	}
+}
+
+func TestTypedContainers(t *testing.T) {
+	linkA := Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPa"}
+	linkB := Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPb"}
+	n := Node{
+		"nodes": []Node{{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"}},
+		"links": []Link{linkA, linkB},
+		"named": map[string]Link{"b": linkB},
+		"ints":  []int{1, 2, 3},
+	}
+
+	var paths []string
+	for p := range Links(n) {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	expected := []string{"links/0", "links/1", "named/b", "nodes/0"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected links %v, got %v", expected, paths)
+	}
+
+	if v := GetPath(n, "/ints/1"); v != 2 {
+		t.Errorf("expected 2, got %#v", v)
+	}
+	if v := GetPath(n, "/named/b"); !reflect.DeepEqual(v, linkB) {
+		t.Errorf("expected %#v, got %#v", linkB, v)
+	}
+
+	var visited []string
+	res, err := Transform(n, func(root, curr Node, path Path, err error) (Node, error) {
+		visited = append(visited, path.String())
+		return nil, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(visited)
+	expected = []string{"", "links/0", "links/1", "named", "named/b", "nodes/0"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("expected visits %v, got %v", expected, visited)
+	}
+	if _, ok := res["links"].([]interface{})[1].(Link); !ok {
+		t.Errorf("links are not kept as Link: %#v", res["links"])
+	}
+	if _, ok := res["ints"].([]int); !ok {
+		t.Errorf("lists of scalars are not kept: %#v", res["ints"])
+	}
+}
@@ -0,0 +1,179 @@
+package ipld
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	b58 "github.com/jbenet/go-base58"
+	mh "github.com/jbenet/go-multihash"
+)
+
+// Multicodec codes of the serialization formats a Cid can refer to.
+const (
+	CodecRaw      = 0x55
+	CodecProtobuf = 0x70
+	CodecCBOR     = 0x71
+	CodecJSON     = 0x0129
+)
+
+// Multibase prefixes understood by ParseCid. Cids are always formatted
+// in base58btc.
+const (
+	MultibaseBase16    = 'f'
+	MultibaseBase32    = 'b'
+	MultibaseBase58BTC = 'z'
+)
+
+var (
+	ErrInvalidCid       = errors.New("invalid cid")
+	ErrUnknownMultibase = errors.New("unknown multibase encoding")
+)
+
+var base32Encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567")
+
+// Cid is a self-describing identifier of a block: the multicodec of its
+// serialization and the multihash of its bytes.
+//
+// Version 0 Cids are legacy base58 multihashes, which do not tell the
+// codec. Their Codec is 0, and the codec must be found from the block
+// header.
+type Cid struct {
+	Version uint64
+	Codec   uint64
+	Hash    mh.Multihash
+}
+
+// NewCid returns a version 1 Cid.
+func NewCid(codec uint64, h mh.Multihash) Cid {
+	return Cid{Version: 1, Codec: codec, Hash: h}
+}
+
+// CidFromBytes decodes the binary representation of a Cid:
+//
+//   <version varint><codec varint><multihash>
+//
+// A bare multihash is decoded as a version 0 Cid.
+func CidFromBytes(data []byte) (Cid, error) {
+	if len(data) > 0 && data[0] != 1 {
+		h, err := mh.Cast(data)
+		return Cid{Hash: h}, err
+	}
+	if len(data) == 0 {
+		return Cid{}, ErrInvalidCid
+	}
+	data = data[1:] // version
+
+	codec, n := binary.Uvarint(data)
+	if n <= 0 {
+		return Cid{}, ErrInvalidCid
+	}
+
+	h, err := mh.Cast(data[n:])
+	if err != nil {
+		return Cid{}, err
+	}
+	return Cid{Version: 1, Codec: codec, Hash: h}, nil
+}
+
+// ParseCid decodes a Cid from its string representation, a multibase
+// string or a legacy base58 multihash.
+func ParseCid(s string) (Cid, error) {
+	c, err := parseMultibaseCid(s)
+	if err == nil {
+		return c, nil
+	}
+
+	h, b58err := mh.FromB58String(s)
+	if b58err != nil {
+		return Cid{}, err
+	}
+	return Cid{Hash: h}, nil
+}
+
+func parseMultibaseCid(s string) (Cid, error) {
+	if len(s) < 2 {
+		return Cid{}, ErrInvalidCid
+	}
+
+	var data []byte
+	var err error
+	switch s[0] {
+	case MultibaseBase58BTC:
+		data = b58.Decode(s[1:])
+		if len(data) == 0 {
+			err = ErrInvalidCid
+		}
+	case MultibaseBase32:
+		data, err = base32Encoding.DecodeString(padBase32(s[1:]))
+	case MultibaseBase16:
+		data, err = hex.DecodeString(s[1:])
+	default:
+		return Cid{}, ErrUnknownMultibase
+	}
+	if err != nil || len(data) == 0 || data[0] != 1 {
+		return Cid{}, ErrInvalidCid
+	}
+	return CidFromBytes(data)
+}
+
+func padBase32(s string) string {
+	if n := len(s) % 8; n != 0 {
+		s += strings.Repeat("=", 8-n)
+	}
+	return s
+}
+
+// Bytes returns the binary representation of c.
+func (c Cid) Bytes() []byte {
+	if c.Version == 0 {
+		return []byte(c.Hash)
+	}
+
+	buf := make([]byte, 2*binary.MaxVarintLen64+len(c.Hash))
+	n := binary.PutUvarint(buf, c.Version)
+	n += binary.PutUvarint(buf[n:], c.Codec)
+	n += copy(buf[n:], c.Hash)
+	return buf[:n]
+}
+
+// String returns the string representation of c: a base58btc multibase
+// string, or a base58 multihash for version 0 Cids.
+func (c Cid) String() string {
+	if c.Version == 0 {
+		return c.Hash.B58String()
+	}
+	return string(MultibaseBase58BTC) + b58.Encode(c.Bytes())
+}
+
+// Equal returns whether two Cids are equal.
+func (c Cid) Equal(c2 Cid) bool {
+	return c.Version == c2.Version && c.Codec == c2.Codec && string(c.Hash) == string(c2.Hash)
+}
+
+// MarshalJSON encodes c as its string representation.
+func (c Cid) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON decodes a Cid from its string representation.
+func (c *Cid) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	cid, err := ParseCid(s)
+	if err != nil {
+		return err
+	}
+	*c = cid
+	return nil
+}
+
+// LinkToCid returns a merkle-link to the block identified by c.
+func LinkToCid(c Cid) Link {
+	return Link{LinkKey: c}
+}
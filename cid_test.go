@@ -0,0 +1,110 @@
+package ipld
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	mh "github.com/jbenet/go-multihash"
+)
+
+func TestCid(t *testing.T) {
+	h, err := Sum([]byte("block"), mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCid(CodecCBOR, h)
+
+	c2, err := CidFromBytes(c.Bytes())
+	if err != nil || !c.Equal(c2) {
+		t.Errorf("binary round-trip failed: %v, %v", c2, err)
+	}
+
+	s := c.String()
+	if s[0] != MultibaseBase58BTC {
+		t.Errorf("expected base58btc multibase, got %s", s)
+	}
+	strs := []string{
+		s,
+		string(MultibaseBase16) + hex.EncodeToString(c.Bytes()),
+		string(MultibaseBase32) + strings.TrimRight(base32Encoding.EncodeToString(c.Bytes()), "="),
+	}
+	for _, str := range strs {
+		c2, err := ParseCid(str)
+		if err != nil || !c.Equal(c2) {
+			t.Errorf("%s: parse failed: %v, %v", str, c2, err)
+		}
+	}
+
+	legacy, err := ParseCid(h.B58String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Version != 0 || legacy.Codec != 0 || legacy.String() != h.B58String() {
+		t.Errorf("unexpected legacy cid %#v", legacy)
+	}
+	if string(legacy.Bytes()) != string(h) {
+		t.Errorf("legacy cid bytes should be the multihash")
+	}
+
+	for _, str := range []string{"", "z", "zzzz", "x1234", "f0171"} {
+		if _, err := ParseCid(str); err == nil {
+			t.Errorf("%q: expected an error", str)
+		}
+	}
+}
+
+func TestCidLinks(t *testing.T) {
+	h, err := Sum([]byte("block"), mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCid(CodecJSON, h)
+
+	links := []Link{
+		LinkToCid(c),
+		{LinkKey: c.String()},
+		{LinkKey: h.B58String()},
+	}
+	for _, l := range links {
+		if !IsLink(Node(l)) {
+			t.Errorf("%#v is not a link", l)
+		}
+		lh, err := l.Hash()
+		if err != nil || string(lh) != string(h) {
+			t.Errorf("%#v: hash mismatch: %v", l, err)
+		}
+	}
+	if lc, err := links[1].Cid(); err != nil || !lc.Equal(c) {
+		t.Errorf("cid mismatch: %v, %v", lc, err)
+	}
+	if links[0].LinkStr() != c.String() {
+		t.Errorf("unexpected link string %s", links[0].LinkStr())
+	}
+
+	data, err := json.Marshal(links[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"mlink":"`+c.String()+`"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	var decoded struct {
+		Target Cid
+	}
+	if err := Unmarshal(Node{"target": c.String()}, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Target.Equal(c) {
+		t.Errorf("unmarshaled cid mismatch: %v", decoded.Target)
+	}
+	n, err := Marshal(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nc, ok := n["target"].(Cid); !ok || !nc.Equal(c) {
+		t.Errorf("marshaled cid mismatch: %#v", n["target"])
+	}
+}
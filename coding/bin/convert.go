@@ -6,26 +6,10 @@ import (
 	"os"
 
 	mc "github.com/jbenet/go-multicodec"
-	pb "github.com/ipfs/go-ipld/coding/pb"
 	ipld "github.com/ipfs/go-ipld"
 	coding "github.com/ipfs/go-ipld/coding"
 )
 
-var codecs []mc.Multicodec = []mc.Multicodec{
-	coding.CborMulticodec(),
-	coding.JsonMulticodec(),
-	pb.Multicodec(),
-}
-
-func codecByName(name string) mc.Multicodec {
-	for _, c := range codecs {
-		if name == string(mc.HeaderPath(c.Header())) {
-			return c
-		}
-	}
-	return nil
-}
-
 func main() {
 	infile  := flag.String("i", "", "Input file")
 	outfile := flag.String("o", "", "Output file")
@@ -43,7 +27,7 @@ func main() {
 		panic(err)
 	}
 
-	codec = codecByName(*codecid)
+	codec = coding.CodecByPath(*codecid)
 	if codec == nil {
 		panic("Could not find codec " + *codecid)
 	}
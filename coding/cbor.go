@@ -0,0 +1,829 @@
+package ipfsld
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+
+	mc "github.com/jbenet/go-multicodec"
+	mccbor "github.com/jbenet/go-multicodec/cbor"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNegint = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// CborTagPosBignum and CborTagNegBignum are the RFC 7049 tags of integers
+// which do not fit in the integer major types. They are decoded to
+// *big.Int.
+const (
+	CborTagPosBignum = 2
+	CborTagNegBignum = 3
+)
+
+// CborTagCid is the CBOR tag of Cid values, which are encoded as a byte
+// string holding a zero byte (the identity multibase prefix) followed by
+// the binary Cid. Legacy links are encoded with their raw multihash as
+// binary Cid.
+const CborTagCid = 42
+
+var (
+	cidType    = reflect.TypeOf(ipld.Cid{})
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+	bigOne     = big.NewInt(1)
+)
+
+// CBOR simple values and additional information
+const (
+	cborFalse      = 20
+	cborTrue       = 21
+	cborNull       = 22
+	cborUndefined  = 23
+	cborFloat16    = 25
+	cborFloat32    = 26
+	cborFloat64    = 27
+	cborIndefinite = 31
+	cborBreak      = 0xff
+
+	// cborMaxPrealloc is the largest string length allocated before its
+	// content is read. Longer strings are read in chunks, so a corrupted
+	// length cannot cause a huge allocation.
+	cborMaxPrealloc = 1 << 16
+
+	// cborShortText is the length up to which text strings are decoded
+	// without an intermediate buffer.
+	cborShortText = 64
+)
+
+var (
+	ErrDuplicateKey    = errors.New("cbor: duplicate map key")
+	ErrUnsupportedType = errors.New("cbor: unsupported type")
+	ErrInvalidCid      = errors.New("cbor: invalid cid")
+	ErrInvalidBignum   = errors.New("cbor: invalid bignum")
+	errBreak           = errors.New("cbor: unexpected break")
+)
+
+// CborKeyMode selects what the CBOR decoder does with map keys which are
+// not text strings. Such keys cannot be represented in an ipld.Node.
+type CborKeyMode int
+
+const (
+	// CborKeysDrop drops the entries whose key is not a string. This is
+	// the behaviour of the default codecs.
+	CborKeysDrop CborKeyMode = iota
+
+	// CborKeysStrict fails decoding with a *CborKeyError.
+	CborKeysStrict
+
+	// CborKeysLossless decodes the maps with non-string keys to a CborMap,
+	// which keeps all their entries when encoded back.
+	CborKeysLossless
+)
+
+// CborKeyError is returned when decoding a map with a non-string key in
+// CborKeysStrict mode.
+type CborKeyError struct {
+	Path string      // path of the map, with keys and indexes separated by "/"
+	Key  interface{} // the offending key
+}
+
+func (e *CborKeyError) Error() string {
+	return fmt.Sprintf("cbor: non-string map key %#v at %q", e.Key, e.Path)
+}
+
+// CborMap is a CBOR map with non-string keys, as decoded in CborKeysLossless
+// mode. Entries are in the order they were decoded, and all the entries of
+// the map are kept, including the ones with string keys.
+type CborMap []CborMapEntry
+
+// CborMapEntry is an entry of a CborMap.
+type CborMapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+var cborMapType = reflect.TypeOf(CborMap{})
+
+type canonicalCborCodec struct {
+	linkTags bool
+	keys     CborKeyMode
+}
+
+type canonicalCborEncoder struct {
+	w        io.Writer
+	linkTags bool
+}
+
+type canonicalCborDecoder struct {
+	r    io.Reader
+	keys CborKeyMode
+}
+
+// CanonicalCborMulticodec returns a CBOR multicodec which produces the
+// canonical encoding described in RFC 7049 section 3.9, so the same
+// logical node always serializes to the same bytes:
+//
+//   - integers and lengths use the shortest possible encoding
+//   - map keys are sorted, shortest first, then in bytewise order
+//   - indefinite-length items are never emitted
+//   - floating point values are always encoded in 64 bits
+//
+// The decoder accepts any well-formed CBOR, but rejects maps containing
+// duplicate keys, as they have no canonical representation. Decoded maps
+// are ipld.Node, arrays are []interface{}, integers are ipld.Int, or
+// *big.Int if they do not fit in 64 bits signed, and floats are
+// ipld.Float. *big.Int values too large for the integer major types are
+// tagged with CborTagPosBignum or CborTagNegBignum, and ipld.Cid values
+// with CborTagCid. Other tags are ignored when decoding.
+//
+// A CborTagCid item is decoded to an ipld.Cid when it is the value of the
+// "mlink" key of a link, and to an ipld.Link to the Cid anywhere else, as
+// it is then a link encoded by LinkTagCborMulticodec.
+func CanonicalCborMulticodec() mc.Multicodec {
+	return &canonicalCborCodec{}
+}
+
+// LinkTagCborMulticodec returns a variant of CanonicalCborMulticodec
+// which encodes ipld.Link values without other properties, such as
+//
+//   ipld.Link{"mlink": "Qm..."}
+//
+// as a CborTagCid tagged Cid, instead of a map. This is more compact, and
+// cannot be mistaken for user data with a "mlink" key: only values of type
+// ipld.Link are tagged, other maps are encoded as maps even when they look
+// like links. Links with other properties are still encoded as maps.
+//
+// Both codecs share the same header and decoder, which reads tagged links
+// back as ipld.Link values, so data encoded by one can be read by the
+// other.
+func LinkTagCborMulticodec() mc.Multicodec {
+	return &canonicalCborCodec{linkTags: true}
+}
+
+// StrictCborMulticodec returns a variant of CanonicalCborMulticodec whose
+// decoder fails with a *CborKeyError on maps with non-string keys, instead
+// of dropping their entries.
+func StrictCborMulticodec() mc.Multicodec {
+	return &canonicalCborCodec{keys: CborKeysStrict}
+}
+
+// LosslessCborMulticodec returns a variant of CanonicalCborMulticodec whose
+// decoder returns maps with non-string keys as CborMap values, so their
+// entries survive a round trip instead of being dropped.
+//
+// Only the keys are preserved: the rest of the block is decoded and encoded
+// like with CanonicalCborMulticodec, so unknown tags are dropped, floats are
+// encoded in 64 bits, and indefinite lengths and key order are normalized.
+// Blocks from other CBOR producers keep their bytes, and their hash, only
+// if they were already canonical.
+func LosslessCborMulticodec() mc.Multicodec {
+	return &canonicalCborCodec{keys: CborKeysLossless}
+}
+
+type cborCodec struct {
+	mc.Multicodec
+}
+
+// CborMulticodec returns the go-multicodec CBOR codec, whose output depends
+// on map iteration order. It is the CBOR codec of the muxing codec by
+// default, so existing objects keep their hashes; the muxing codec encodes
+// canonically once CanonicalCborMulticodec is registered in its place:
+//
+//   Register(CanonicalCborMulticodec())
+//
+// Values are decoded like CanonicalCborMulticodec does, but the encoder
+// does not support *big.Int values.
+func CborMulticodec() mc.Multicodec {
+	return &cborCodec{mccbor.Multicodec()}
+}
+
+func (c *cborCodec) Decoder(r io.Reader) mc.Decoder {
+	return &canonicalCborDecoder{r, CborKeysDrop}
+}
+
+func (c *canonicalCborCodec) Header() []byte {
+	return mccbor.Header
+}
+
+func (c *canonicalCborCodec) Encoder(w io.Writer) mc.Encoder {
+	return &canonicalCborEncoder{w, c.linkTags}
+}
+
+func (c *canonicalCborCodec) Decoder(r io.Reader) mc.Decoder {
+	return &canonicalCborDecoder{r, c.keys}
+}
+
+func (e *canonicalCborEncoder) Encode(v interface{}) error {
+	var buf bytes.Buffer
+	buf.Write(mccbor.Header)
+	if err := encodeCbor(&buf, reflect.ValueOf(v), e.linkTags); err != nil {
+		return err
+	}
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func (d *canonicalCborDecoder) Decode(v interface{}) error {
+	if err := mc.ConsumeHeader(d.r, mccbor.Header); err != nil {
+		return err
+	}
+
+	val, err := decodeCbor(d.r, d.keys)
+	if err == errBreak {
+		return fmt.Errorf("cbor: unexpected break")
+	} else if err != nil {
+		return err
+	}
+
+	return setDecoded(v, val)
+}
+
+// setDecoded stores a decoded value in v, which must be a pointer to an
+// interface{}, an ipld.Node or a map[string]interface{}.
+func setDecoded(v interface{}, val interface{}) error {
+	switch vt := v.(type) {
+	case *interface{}:
+		*vt = val
+	case *ipld.Node:
+		n, ok := val.(ipld.Node)
+		if !ok {
+			return mc.ErrType
+		}
+		*vt = n
+	case *map[string]interface{}:
+		n, ok := val.(ipld.Node)
+		if !ok {
+			return mc.ErrType
+		}
+		*vt = map[string]interface{}(n)
+	default:
+		return mc.ErrType
+	}
+	return nil
+}
+
+// writeCborHead writes the initial byte and argument of an item using the
+// shortest possible encoding.
+func writeCborHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func encodeCbor(buf *bytes.Buffer, v reflect.Value, linkTags bool) error {
+	if !v.IsValid() {
+		buf.WriteByte(cborSimple<<5 | cborNull)
+		return nil
+	}
+
+	if v.Type() == cborMapType {
+		return encodeCborEntries(buf, v.Interface().(CborMap), linkTags)
+	}
+
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			buf.WriteByte(cborSimple<<5 | cborNull)
+			return nil
+		}
+		encodeCborBigInt(buf, v.Interface().(*big.Int))
+		return nil
+	}
+
+	if v.Type() == cidType {
+		encodeCborCid(buf, v.Interface().(ipld.Cid))
+		return nil
+	}
+
+	if linkTags && v.Type() == linkType {
+		if c, ok := linkCid(v.Interface().(ipld.Link)); ok {
+			encodeCborCid(buf, c)
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteByte(cborSimple<<5 | cborNull)
+			return nil
+		}
+		return encodeCbor(buf, v.Elem(), linkTags)
+
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(cborSimple<<5 | cborTrue)
+		} else {
+			buf.WriteByte(cborSimple<<5 | cborFalse)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if i < 0 {
+			writeCborHead(buf, cborNegint, uint64(-1-i))
+		} else {
+			writeCborHead(buf, cborUint, uint64(i))
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeCborHead(buf, cborUint, v.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		buf.WriteByte(cborSimple<<5 | cborFloat64)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v.Float()))
+
+	case reflect.String:
+		writeCborHead(buf, cborText, uint64(v.Len()))
+		buf.WriteString(v.String())
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(cborSimple<<5 | cborNull)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			writeCborHead(buf, cborBytes, uint64(len(b)))
+			buf.Write(b)
+			return nil
+		}
+		writeCborHead(buf, cborArray, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeCbor(buf, v.Index(i), linkTags); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(cborSimple<<5 | cborNull)
+			return nil
+		}
+		return encodeCborMap(buf, v, linkTags)
+
+	default:
+		return ErrUnsupportedType
+	}
+	return nil
+}
+
+// encodeCborBigInt writes i as an integer if it fits in the integer major
+// types, or as a bignum.
+func encodeCborBigInt(buf *bytes.Buffer, i *big.Int) {
+	major, tag := byte(cborUint), uint64(CborTagPosBignum)
+	m := i
+	if i.Sign() < 0 {
+		// negative integers are encoded as -1 - i
+		major, tag = cborNegint, CborTagNegBignum
+		m = new(big.Int).Neg(i)
+		m.Sub(m, bigOne)
+	}
+
+	if m.BitLen() <= 64 {
+		writeCborHead(buf, major, m.Uint64())
+		return
+	}
+	b := m.Bytes()
+	writeCborHead(buf, cborTag, tag)
+	writeCborHead(buf, cborBytes, uint64(len(b)))
+	buf.Write(b)
+}
+
+// encodeCborCid writes c as a CborTagCid tagged byte string.
+func encodeCborCid(buf *bytes.Buffer, c ipld.Cid) {
+	b := append([]byte{0}, c.Bytes()...)
+	writeCborHead(buf, cborTag, CborTagCid)
+	writeCborHead(buf, cborBytes, uint64(len(b)))
+	buf.Write(b)
+}
+
+// linkCid returns the target of l if it holds a merkle-link and nothing
+// else.
+func linkCid(l ipld.Link) (ipld.Cid, bool) {
+	if len(l) != 1 {
+		return ipld.Cid{}, false
+	}
+	c, err := l.Cid()
+	return c, err == nil
+}
+
+// cidLink returns a link to c, as decoded from a tagged Cid which is not
+// the value of an "mlink" key.
+func cidLink(c ipld.Cid) ipld.Link {
+	return ipld.Link{ipld.LinkKey: linkValue(c)}
+}
+
+type cborEntry struct {
+	key []byte // encoded key
+	val reflect.Value
+}
+
+type cborEntries []cborEntry
+
+func (e cborEntries) Len() int      { return len(e) }
+func (e cborEntries) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e cborEntries) Less(i, j int) bool {
+	return cborKeyLess(e[i].key, e[j].key)
+}
+
+// cborKeyLess implements the RFC 7049 canonical ordering of encoded keys:
+// shorter keys sort first, keys of the same length sort bytewise.
+func cborKeyLess(a, b []byte) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return bytes.Compare(a, b) < 0
+}
+
+func encodeCborMap(buf *bytes.Buffer, v reflect.Value, linkTags bool) error {
+	entries := make(cborEntries, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		var kbuf bytes.Buffer
+		if err := encodeCbor(&kbuf, k, linkTags); err != nil {
+			return err
+		}
+		entries = append(entries, cborEntry{kbuf.Bytes(), v.MapIndex(k)})
+	}
+	return writeCborEntries(buf, entries, linkTags)
+}
+
+// encodeCborEntries encodes m as a map. The entries are sorted like the
+// entries of other maps.
+func encodeCborEntries(buf *bytes.Buffer, m CborMap, linkTags bool) error {
+	entries := make(cborEntries, 0, len(m))
+	for _, e := range m {
+		var kbuf bytes.Buffer
+		if err := encodeCbor(&kbuf, reflect.ValueOf(e.Key), linkTags); err != nil {
+			return err
+		}
+		entries = append(entries, cborEntry{kbuf.Bytes(), reflect.ValueOf(e.Value)})
+	}
+	return writeCborEntries(buf, entries, linkTags)
+}
+
+// writeCborEntries writes a map holding entries, in canonical order.
+func writeCborEntries(buf *bytes.Buffer, entries cborEntries, linkTags bool) error {
+	sort.Sort(entries)
+
+	writeCborHead(buf, cborMap, uint64(len(entries)))
+	for _, e := range entries {
+		buf.Write(e.key)
+		if err := encodeCbor(buf, e.val, linkTags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCborHead reads the initial byte of an item and its argument. For
+// indefinite-length items, indef is true and n is zero.
+func readCborHead(r io.Reader) (major, info byte, n uint64, indef bool, err error) {
+	var b byte
+	if b, err = readCborByte(r); err != nil {
+		return
+	}
+	major, info = b>>5, b&0x1f
+
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info == 24:
+		b, err = readCborByte(r)
+		n = uint64(b)
+	case info == 25:
+		n, err = readCborUint(r, 2)
+	case info == 26:
+		n, err = readCborUint(r, 4)
+	case info == 27:
+		n, err = readCborUint(r, 8)
+	case info == cborIndefinite:
+		indef = true
+	default:
+		err = fmt.Errorf("cbor: invalid additional information %d", info)
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// readCborByte reads a single byte, without allocating when r is an
+// io.ByteReader.
+func readCborByte(r io.Reader) (byte, error) {
+	if br, ok := r.(io.ByteReader); ok {
+		return br.ReadByte()
+	}
+	var b [1]byte
+	_, err := io.ReadFull(r, b[:])
+	return b[0], err
+}
+
+// readCborUint reads a big endian unsigned integer of size bytes.
+func readCborUint(r io.Reader, size int) (uint64, error) {
+	if br, ok := r.(io.ByteReader); ok {
+		var n uint64
+		for i := 0; i < size; i++ {
+			b, err := br.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			n = n<<8 | uint64(b)
+		}
+		return n, nil
+	}
+
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+// readCborString reads a definite or indefinite byte or text string.
+func readCborString(r io.Reader, major byte, n uint64, indef bool) ([]byte, error) {
+	if !indef && n <= cborMaxPrealloc {
+		// the length is small enough to be trusted and allocated at once.
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return b, nil
+	}
+
+	var buf bytes.Buffer
+	if !indef {
+		if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	for {
+		cmajor, info, cn, cindef, err := readCborHead(r)
+		if err != nil {
+			return nil, err
+		}
+		if cmajor == cborSimple && info == cborIndefinite {
+			return buf.Bytes(), nil
+		}
+		if cmajor != major || cindef {
+			return nil, fmt.Errorf("cbor: invalid indefinite string chunk")
+		}
+		chunk, err := readCborString(r, major, cn, false)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(chunk)
+	}
+}
+
+// decodeCbor reads a single data item from r. It returns errBreak if the
+// item is a break code, which callers decoding indefinite-length items
+// use as terminator. Tagged Cids are returned as links.
+func decodeCbor(r io.Reader, keys CborKeyMode) (interface{}, error) {
+	v, err := decodeCborValue(r, keys)
+	if c, ok := v.(ipld.Cid); ok {
+		return cidLink(c), err
+	}
+	return v, err
+}
+
+// decodeCborValue reads a single data item from r, like decodeCbor, but
+// returns tagged Cids as ipld.Cid.
+func decodeCborValue(r io.Reader, keys CborKeyMode) (interface{}, error) {
+	major, info, n, indef, err := readCborHead(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCborItem(r, major, info, n, indef, keys)
+}
+
+// decodeCborItem reads the rest of the data item whose head was read.
+// keys tells what to do with non-string map keys.
+func decodeCborItem(r io.Reader, major, info byte, n uint64, indef bool, keys CborKeyMode) (interface{}, error) {
+	if indef && (major == cborUint || major == cborNegint || major == cborTag) {
+		return nil, fmt.Errorf("cbor: invalid indefinite length for major type %d", major)
+	}
+
+	switch major {
+	case cborUint:
+		return ipld.Uint(n), nil
+
+	case cborNegint:
+		if n > math.MaxInt64 {
+			b := new(big.Int).SetUint64(n)
+			return b.Neg(b).Sub(b, bigOne), nil
+		}
+		return ipld.Int(-1 - int64(n)), nil
+
+	case cborBytes:
+		return readCborString(r, major, n, indef)
+
+	case cborText:
+		if br, ok := r.(io.ByteReader); ok && !indef && n <= cborShortText {
+			// short keys and values are read on the stack, so the
+			// string is the only allocation.
+			var b [cborShortText]byte
+			for i := range b[:n] {
+				c, err := br.ReadByte()
+				if err == io.EOF {
+					return nil, io.ErrUnexpectedEOF
+				} else if err != nil {
+					return nil, err
+				}
+				b[i] = c
+			}
+			return string(b[:n]), nil
+		}
+		b, err := readCborString(r, major, n, indef)
+		return string(b), err
+
+	case cborArray:
+		s := []interface{}{}
+		for i := uint64(0); indef || i < n; i++ {
+			v, err := decodeCbor(r, keys)
+			if indef && err == errBreak {
+				break
+			} else if err != nil {
+				return nil, prefixKeyError(err, fmt.Sprint(i))
+			}
+			s = append(s, v)
+		}
+		return s, nil
+
+	case cborMap:
+		return decodeCborMap(r, n, indef, keys)
+
+	case cborTag:
+		v, err := decodeCborValue(r, keys)
+		if err != nil {
+			return v, err
+		}
+		switch n {
+		case CborTagPosBignum, CborTagNegBignum:
+			b, ok := v.([]byte)
+			if !ok {
+				return nil, ErrInvalidBignum
+			}
+			i := new(big.Int).SetBytes(b)
+			if n == CborTagNegBignum {
+				i.Neg(i).Sub(i, bigOne)
+			}
+			return ipld.BigInt(i), nil
+		case CborTagCid:
+			b, ok := v.([]byte)
+			if !ok || len(b) == 0 || b[0] != 0 {
+				return nil, ErrInvalidCid
+			}
+			return ipld.CidFromBytes(b[1:])
+		}
+		return v, nil
+
+	default: // cborSimple
+		switch info {
+		case cborFalse:
+			return false, nil
+		case cborTrue:
+			return true, nil
+		case cborNull, cborUndefined:
+			return nil, nil
+		case cborFloat16:
+			return ipld.Float(float16to64(uint16(n))), nil
+		case cborFloat32:
+			return ipld.Float(math.Float32frombits(uint32(n))), nil
+		case cborFloat64:
+			return ipld.Float(math.Float64frombits(n)), nil
+		case cborIndefinite:
+			return nil, errBreak
+		}
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", n)
+	}
+}
+
+func decodeCborMap(r io.Reader, n uint64, indef bool, keys CborKeyMode) (interface{}, error) {
+	node := ipld.Node{}
+	var seen map[string]bool // non-string keys
+	var entries CborMap      // all entries, in CborKeysLossless mode
+	for i := uint64(0); indef || i < n; i++ {
+		k, err := decodeCbor(r, keys)
+		if indef && err == errBreak {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		ks, isString := k.(string)
+		var v interface{}
+		if isString && ks == ipld.LinkKey {
+			v, err = decodeCborValue(r, keys) // the Cid of a link
+		} else {
+			v, err = decodeCbor(r, keys)
+		}
+		if err != nil {
+			if isString {
+				err = prefixKeyError(err, ks)
+			}
+			return nil, err
+		}
+		if keys == CborKeysLossless {
+			entries = append(entries, CborMapEntry{k, v})
+		}
+
+		// duplicates are rejected for all keys.
+		if isString {
+			if _, dup := node[ks]; dup {
+				return nil, ErrDuplicateKey
+			}
+			node[ks] = v
+			continue
+		}
+		if keys == CborKeysStrict {
+			return nil, &CborKeyError{Key: k}
+		}
+		if seen == nil {
+			seen = map[string]bool{}
+		}
+		sk := fmt.Sprintf("%T:%#v", k, k)
+		if seen[sk] {
+			return nil, ErrDuplicateKey
+		}
+		seen[sk] = true
+	}
+
+	// as with the other codecs, only string keys are kept, unless
+	// requested otherwise.
+	if seen != nil && keys == CborKeysLossless {
+		return entries, nil
+	}
+	return node, nil
+}
+
+// prefixKeyError prepends elem to the path of err if it is a
+// *CborKeyError, as it is returned up from the map holding the key.
+func prefixKeyError(err error, elem string) error {
+	if e, ok := err.(*CborKeyError); ok {
+		if e.Path == "" {
+			e.Path = elem
+		} else {
+			e.Path = elem + "/" + e.Path
+		}
+	}
+	return err
+}
+
+// float16to64 converts an IEEE 754 half-precision float to float64.
+func float16to64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1.0
+	}
+	exp := int(h>>10) & 0x1f
+	frac := float64(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(frac+1024, exp-25)
+}
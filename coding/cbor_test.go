@@ -0,0 +1,437 @@
+package ipfsld
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"testing"
+
+	mc "github.com/jbenet/go-multicodec"
+	mccbor "github.com/jbenet/go-multicodec/cbor"
+	mcmux "github.com/jbenet/go-multicodec/mux"
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+func encodeCborBytes(t *testing.T, v interface{}) []byte {
+	var buf bytes.Buffer
+	if err := encodeCbor(&buf, reflect.ValueOf(v), false); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Examples taken from RFC 7049 appendix A
+func TestCanonicalCborEncoding(t *testing.T) {
+	cases := []struct {
+		v   interface{}
+		hex string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{uint8(100), "1864"},
+		{1000, "1903e8"},
+		{int64(1000000), "1a000f4240"},
+		{uint64(1000000000000), "1b000000e8d4a51000"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{ipld.Int(-1000), "3903e7"},
+		{bigInt("18446744073709551615"), "1bffffffffffffffff"},
+		{bigInt("-18446744073709551616"), "3bffffffffffffffff"},
+		{bigInt("18446744073709551616"), "c249010000000000000000"},
+		{bigInt("-18446744073709551617"), "c349010000000000000000"},
+		{1.1, "fb3ff199999999999a"},
+		{ipld.Float(1), "fb3ff0000000000000"},
+		{false, "f4"},
+		{true, "f5"},
+		{nil, "f6"},
+		{"", "60"},
+		{"IETF", "6449455446"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]int{1, 2, 3}, "83010203"},
+		{[]interface{}{1, []int{2, 3}}, "8201820203"},
+		{ipld.Node{"a": 1, "b": []int{2, 3}}, "a26161016162820203"},
+		{ipld.Node{"aa": 1, "b": 2, "c": 3}, "a361620261630362616101"},
+	}
+
+	for _, c := range cases {
+		b := encodeCborBytes(t, c.v)
+		if hex.EncodeToString(b) != c.hex {
+			t.Errorf("%#v: expected %s, got %x", c.v, c.hex, b)
+		}
+	}
+}
+
+func TestCanonicalCborDeterministic(t *testing.T) {
+	n := ipld.Node{}
+	for _, k := range []string{"z", "a", "bb", "@type", "mlink", "0", "10", "9", "\\@foo"} {
+		n[k] = ipld.Node{"k": k, "z": 1, "a": 2}
+	}
+
+	expected := encodeCborBytes(t, n)
+	for i := 0; i < 50; i++ {
+		// rebuild the map so it is iterated in a different order.
+		n2 := ipld.Node{}
+		for k, v := range n {
+			n2[k] = v
+		}
+		if b := encodeCborBytes(t, n2); !bytes.Equal(expected, b) {
+			t.Fatalf("encoding is not deterministic:\n%x\n%x", expected, b)
+		}
+	}
+}
+
+func TestCanonicalCborDecoding(t *testing.T) {
+	cases := []struct {
+		hex string
+		v   interface{}
+	}{
+		{"a26161016162820203", ipld.Node{"a": ipld.Int(1), "b": []interface{}{ipld.Int(2), ipld.Int(3)}}},
+		{"bf61610161629f0203ffff", ipld.Node{"a": ipld.Int(1), "b": []interface{}{ipld.Int(2), ipld.Int(3)}}},
+		{"a1616139fffe", ipld.Node{"a": ipld.Int(-65535)}},
+		{"a16161f93c00", ipld.Node{"a": ipld.Float(1)}},
+		{"a16161fa47c35000", ipld.Node{"a": ipld.Float(100000)}},
+		{"a161615f42010243030405ff", ipld.Node{"a": []byte{1, 2, 3, 4, 5}}},
+		{"a16161c11a514b67b0", ipld.Node{"a": ipld.Int(1363896240)}},
+		{"a2616101010f", ipld.Node{"a": ipld.Int(1)}},
+		{"a161611bffffffffffffffff", ipld.Node{"a": bigInt("18446744073709551615")}},
+		{"a161613bffffffffffffffff", ipld.Node{"a": bigInt("-18446744073709551616")}},
+		{"a16161c249010000000000000000", ipld.Node{"a": bigInt("18446744073709551616")}},
+		{"a16161c3420100", ipld.Node{"a": ipld.Int(-257)}},
+	}
+
+	for _, c := range cases {
+		data, _ := hex.DecodeString(c.hex)
+		var n ipld.Node
+		err := mc.Unmarshal(CanonicalCborMulticodec(), append(mccbor.Header, data...), &n)
+		if err != nil {
+			t.Errorf("%s: %s", c.hex, err)
+			continue
+		}
+		if !reflect.DeepEqual(n, c.v) {
+			t.Errorf("%s: expected %#v, got %#v", c.hex, c.v, n)
+		}
+	}
+
+	errors := []string{
+		"a2616101616102",   // duplicate key
+		"a2016101016102",   // duplicate integer key
+		"a16161ff",         // unexpected break
+		"a161617f61616161", // unterminated indefinite string
+		"a2616201",         // truncated
+	}
+	for _, e := range errors {
+		data, _ := hex.DecodeString(e)
+		var n ipld.Node
+		err := mc.Unmarshal(CanonicalCborMulticodec(), append(mccbor.Header, data...), &n)
+		if err == nil {
+			t.Errorf("%s: expected error, got %#v", e, n)
+		}
+	}
+
+	data, _ := hex.DecodeString("a2616101616102")
+	var n ipld.Node
+	err := mc.Unmarshal(CanonicalCborMulticodec(), append(mccbor.Header, data...), &n)
+	if err != ErrDuplicateKey {
+		t.Errorf("expected ErrDuplicateKey, got %v", err)
+	}
+}
+
+func TestCanonicalCborCid(t *testing.T) {
+	h, err := ipld.Sum([]byte("block"), mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ipld.NewCid(ipld.CodecCBOR, h)
+	n := ipld.Node{"parent": ipld.Node(ipld.LinkToCid(c))}
+
+	b := encodeCborBytes(t, n)
+	expected := "a166706172656e74a1656d6c696e6bd82a5825000171" + hex.EncodeToString(h)
+	if hex.EncodeToString(b) != expected {
+		t.Errorf("expected %s, got %x", expected, b)
+	}
+
+	var res ipld.Node
+	if err := mc.Unmarshal(CanonicalCborMulticodec(), append(mccbor.Header, b...), &res); err != nil {
+		t.Fatal(err)
+	}
+	l, ok := ipld.LinkCast(res["parent"])
+	if !ok {
+		t.Fatalf("expected a link, got %#v", res["parent"])
+	}
+	if lc, err := l.Cid(); err != nil || !lc.Equal(c) {
+		t.Errorf("cid mismatch: %v, %v", lc, err)
+	}
+
+	invalid, _ := hex.DecodeString("a16161d82a43010203")
+	if err := mc.Unmarshal(CanonicalCborMulticodec(), append(mccbor.Header, invalid...), &res); err == nil {
+		t.Errorf("expected an error decoding an invalid cid")
+	}
+}
+
+func TestLinkTagCbor(t *testing.T) {
+	h, err := ipld.Sum([]byte("block"), mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ipld.NewCid(ipld.CodecCBOR, h)
+
+	n := ipld.Node{
+		"legacy": ipld.Link{"mlink": h.B58String()},
+		"cid":    ipld.LinkToCid(c),
+		"named":  ipld.Link{"mlink": h.B58String(), "name": "foo"},
+		"user":   ipld.Node{"mlink": h.B58String()},
+	}
+
+	data, err := mc.Marshal(LinkTagCborMulticodec(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := "d82a5823" + "00" + hex.EncodeToString(h)
+	if !bytes.Contains(data, mustDecodeHex(tagged)) {
+		t.Errorf("expected legacy link encoded as %s in %x", tagged, data)
+	}
+
+	// user data with a "mlink" key is not tagged
+	var user bytes.Buffer
+	user.Write(mustDecodeHex("a1656d6c696e6b782e"))
+	user.WriteString(h.B58String())
+	if !bytes.Contains(data, user.Bytes()) {
+		t.Errorf("expected user data encoded as a map in %x", data)
+	}
+
+	mapForm, err := mc.Marshal(CanonicalCborMulticodec(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(mapForm) {
+		t.Errorf("tagged links are not smaller: %d >= %d bytes", len(data), len(mapForm))
+	}
+
+	// both codecs read tagged links as ipld.Link values, and the other
+	// maps as nodes.
+	for _, codec := range []mc.Multicodec{CanonicalCborMulticodec(), LinkTagCborMulticodec(), Multicodec()} {
+		b := data
+		if bytes.Equal(codec.Header(), mcmux.Header) {
+			b = append(append([]byte{}, mcmux.Header...), data...)
+		}
+		var res ipld.Node
+		if err := mc.Unmarshal(codec, b, &res); err != nil {
+			t.Fatal(err)
+		}
+		expected := ipld.Node{
+			"legacy": ipld.Link{"mlink": h.B58String()},
+			"cid":    ipld.LinkToCid(c),
+			"named":  ipld.Node{"mlink": h.B58String(), "name": "foo"},
+			"user":   ipld.Node{"mlink": h.B58String()},
+		}
+		if !reflect.DeepEqual(expected, res) {
+			t.Errorf("expected %#v, got %#v", expected, res)
+		}
+
+		// the token reader returns the same values
+		tr, err := NewTokenReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := ReadValue(tr); err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("token reader: expected %#v, got %#v, %v", expected, v, err)
+		}
+	}
+
+	// the map form is read back as nodes
+	var res ipld.Node
+	if err := mc.Unmarshal(LinkTagCborMulticodec(), mapForm, &res); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res["legacy"].(ipld.Node); !ok || !ipld.IsLink(res["legacy"]) {
+		t.Errorf("expected a link node, got %#v", res["legacy"])
+	}
+
+	// tagged links are encoded back to the same bytes
+	res = nil
+	if err := mc.Unmarshal(LinkTagCborMulticodec(), data, &res); err != nil {
+		t.Fatal(err)
+	}
+	res["named"] = ipld.Link(res["named"].(ipld.Node))
+	again, err := mc.Marshal(LinkTagCborMulticodec(), res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("expected %x, got %x", data, again)
+	}
+}
+
+func TestLinkTagCborMarshal(t *testing.T) {
+	h, err := ipld.Sum([]byte("block"), mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type commit struct {
+		Parent ipld.Link
+	}
+	n, err := ipld.Marshal(commit{Parent: ipld.Link{"mlink": h.B58String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := mc.Marshal(LinkTagCborMulticodec(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := "d82a5823" + "00" + hex.EncodeToString(h)
+	if !bytes.Contains(data, mustDecodeHex(tagged)) {
+		t.Errorf("expected the Link field encoded as %s in %x", tagged, data)
+	}
+}
+
+func TestCborMulticodec(t *testing.T) {
+	n := ipld.Node{
+		"foo": "bar",
+		"baz": []interface{}{ipld.Int(1), "two", ipld.Node{"three": true}},
+	}
+	data, err := mc.Marshal(CborMulticodec(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res ipld.Node
+	if err := mc.Unmarshal(CborMulticodec(), data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, res) {
+		t.Errorf("expected %#v, got %#v", n, res)
+	}
+}
+
+func TestCborNonStringKeys(t *testing.T) {
+	// {"a": [{1: "x", h'01': "y", "b": true}]}
+	data := append(append([]byte{}, mccbor.Header...),
+		mustDecodeHex("a1616181a3016178410161796162f5")...)
+
+	var dropped ipld.Node
+	if err := mc.Unmarshal(CanonicalCborMulticodec(), data, &dropped); err != nil {
+		t.Fatal(err)
+	}
+	expected := ipld.Node{"a": []interface{}{ipld.Node{"b": true}}}
+	if !reflect.DeepEqual(dropped, expected) {
+		t.Errorf("expected %#v, got %#v", expected, dropped)
+	}
+
+	var res ipld.Node
+	err := mc.Unmarshal(StrictCborMulticodec(), data, &res)
+	kerr, ok := err.(*CborKeyError)
+	if !ok {
+		t.Fatalf("expected a *CborKeyError, got %v", err)
+	}
+	if kerr.Path != "a/0" || kerr.Key != ipld.Int(1) {
+		t.Errorf("unexpected error %#v", kerr)
+	}
+
+	var lossless ipld.Node
+	if err := mc.Unmarshal(LosslessCborMulticodec(), data, &lossless); err != nil {
+		t.Fatal(err)
+	}
+	expected = ipld.Node{"a": []interface{}{CborMap{
+		{ipld.Int(1), "x"},
+		{[]byte{1}, "y"},
+		{"b", true},
+	}}}
+	if !reflect.DeepEqual(lossless, expected) {
+		t.Errorf("expected %#v, got %#v", expected, lossless)
+	}
+
+	encoded, err := mc.Marshal(LosslessCborMulticodec(), lossless)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("expected %x, got %x", data, encoded)
+	}
+}
+
+func TestCborLosslessNonCanonical(t *testing.T) {
+	// {_ "a": 1.5 (float32), 1: "x", "t": 1(1)}
+	data := append(append([]byte{}, mccbor.Header...),
+		mustDecodeHex("bf6161fa3fc000000161786174c101ff")...)
+
+	var res interface{}
+	if err := mc.Unmarshal(LosslessCborMulticodec(), data, &res); err != nil {
+		t.Fatal(err)
+	}
+	expected := CborMap{
+		{"a", ipld.Float(1.5)},
+		{ipld.Int(1), "x"},
+		{"t", ipld.Int(1)},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %#v, got %#v", expected, res)
+	}
+
+	// the entries are kept, but the block is encoded canonically.
+	encoded, err := mc.Marshal(LosslessCborMulticodec(), res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical := append(append([]byte{}, mccbor.Header...),
+		mustDecodeHex("a30161786161fb3ff8000000000000617401")...)
+	if !bytes.Equal(encoded, canonical) {
+		t.Errorf("expected %x, got %x", canonical, encoded)
+	}
+
+	if err := mc.Unmarshal(LosslessCborMulticodec(), canonical, &res); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err = mc.Marshal(LosslessCborMulticodec(), res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, canonical) {
+		t.Errorf("canonical block: expected %x, got %x", canonical, encoded)
+	}
+}
+
+// The muxing codec keeps the go-multicodec CBOR encoder, which encodes
+// float32 values in 32 bits, unless CanonicalCborMulticodec is registered.
+func TestCborMuxDefault(t *testing.T) {
+	n := ipld.Node{"f": float32(1.5)}
+	data, err := mc.Marshal(Multicodec(), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := append(append([]byte{}, mcmux.Header...), mccbor.Header...)
+	expected := append(header, mustDecodeHex("a16166fa3fc00000")...)
+	if !bytes.Equal(data, expected) {
+		t.Errorf("expected %x, got %x", expected, data)
+	}
+
+	defer saveRegistry()()
+	Register(CanonicalCborMulticodec())
+	if data, err = mc.Marshal(Multicodec(), &n); err != nil {
+		t.Fatal(err)
+	}
+	expected = append(header[:len(header):len(header)], mustDecodeHex("a16166fb3ff8000000000000")...)
+	if !bytes.Equal(data, expected) {
+		t.Errorf("canonical: expected %x, got %x", expected, data)
+	}
+}
+
+func bigInt(s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid integer " + s)
+	}
+	return i
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
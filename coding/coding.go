@@ -21,16 +21,25 @@ var muxCodec *mcmux.Multicodec
 func init() {
 	// by default, always encode things as cbor
 	defaultCodec = string(mc.HeaderPath(mccbor.Header))
-	muxCodec = mcmux.MuxMulticodec([]mc.Multicodec{
-		CborMulticodec(),
-		JsonMulticodec(),
-		pb.Multicodec(),
-	}, selectCodec)
+	// the go-multicodec CBOR encoder is kept so existing objects keep
+	// their bytes and hashes; register CanonicalCborMulticodec to opt in
+	// to canonical CBOR. Both decoders also read links encoded as tags by
+	// LinkTagCborMulticodec.
+	Register(CborMulticodec())
+	Register(JsonMulticodec())
+	Register(pb.Multicodec())
+	muxCodec = mcmux.MuxMulticodec(registered, selectCodec)
+
+	// hash nodes with the same serialization they are stored with.
+	ipld.SetMarshaler(func(n ipld.Node) ([]byte, error) {
+		return mc.Marshal(muxCodec, &n)
+	})
 }
 
 // Multicodec returns a muxing codec that marshals to
 // whatever codec makes sense depending on what information
-// the IPLD object itself carries
+// the IPLD object itself carries. It uses the codecs added
+// with Register.
 func Multicodec() mc.Multicodec {
 	return muxCodec
 }
@@ -46,13 +55,7 @@ func selectCodec(v interface{}, codecs []mc.Multicodec) mc.Multicodec {
 		return nil
 	}
 
-	for _, c := range codecs {
-		if codecKey == string(mc.HeaderPath(c.Header())) {
-			return c
-		}
-	}
-
-	return nil // no codec
+	return CodecByPath(codecKey) // nil if no codec
 }
 
 func codecKey(n ipld.Node) (string, error) {
@@ -2,6 +2,7 @@ package ipfsld
 
 import (
 	"io/ioutil"
+	"math"
 	"testing"
 	"reflect"
 	"bytes"
@@ -9,6 +10,8 @@ import (
 	ipld "github.com/ipfs/go-ipld"
 
 	mc "github.com/jbenet/go-multicodec"
+	mccbor "github.com/jbenet/go-multicodec/cbor"
+	mcjson "github.com/jbenet/go-multicodec/json"
 	mctest "github.com/jbenet/go-multicodec/test"
 )
 
@@ -148,3 +151,167 @@ func TestCodecsDecodeEncode(t *testing.T) {
 	}
 }
 
+func TestHash(t *testing.T) {
+	nodes := []ipld.Node{
+		ipld.Node{
+			"foo": "bar",
+		},
+		ipld.Node{
+			"@codec": "/json",
+			"foo":    "bar",
+			"baz": ipld.Node{
+				"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo",
+			},
+		},
+	}
+
+	for _, n := range nodes {
+		encoded, err := mc.Marshal(Multicodec(), &n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ipld.Sum(encoded, ipld.DefaultHashFunc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := n.Multihash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(h, expected) {
+			t.Errorf("hash mismatch: %s != %s", h.B58String(), expected.B58String())
+		}
+	}
+}
+
+// Numbers keep their kind when converted from JSON to CBOR and back, so
+// the JSON bytes do not change.
+func TestNumbersJsonCborJson(t *testing.T) {
+	src := []byte(`{"big":18446744073709551616,"float":1.0,"int":-42,"list":[0,2.5,1e+21],"small":-9223372036854775808}`)
+	expected := ipld.Node{
+		"big":   bigInt("18446744073709551616"),
+		"float": ipld.Float(1),
+		"int":   ipld.Int(-42),
+		"list":  []interface{}{ipld.Int(0), ipld.Float(2.5), ipld.Float(1e21)},
+		"small": ipld.Int(math.MinInt64),
+	}
+
+	var n ipld.Node
+	if err := mc.Unmarshal(JsonMulticodec(), append(append([]byte{}, mcjson.Header...), src...), &n); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, n)
+	}
+
+	for _, codec := range []mc.Multicodec{CanonicalCborMulticodec(), LinkTagCborMulticodec()} {
+		data, err := mc.Marshal(codec, &n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res ipld.Node
+		if err := mc.Unmarshal(codec, data, &res); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("expected %#v, got %#v", expected, res)
+			continue
+		}
+
+		out, err := mc.Marshal(JsonMulticodec(), &res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = bytes.TrimSuffix(bytes.TrimPrefix(out, mcjson.Header), []byte("\n"))
+		if !bytes.Equal(out, src) {
+			t.Errorf("expected %s, got %s", src, out)
+		}
+	}
+}
+
+// benchNode returns a node with nested maps, lists and links, to measure
+// decoding.
+func benchNode() ipld.Node {
+	entries := []interface{}{}
+	for i := 0; i < 32; i++ {
+		entries = append(entries, ipld.Node{
+			"name": "entry",
+			"size": i * 1024,
+			"tags": []interface{}{"a", "b", "c"},
+			"data": ipld.Node{
+				"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo",
+			},
+		})
+	}
+	return ipld.Node{
+		"@codec":  "/json",
+		"entries": entries,
+	}
+}
+
+// convertNodes is the pass which used to be applied after decoding with
+// the go-multicodec codecs, replacing their maps by ipld.Node.
+func convertNodes(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		n := ipld.Node{}
+		for k, e := range v {
+			n[k] = convertNodes(e)
+		}
+		return n
+	case map[interface{}]interface{}:
+		n := ipld.Node{}
+		for k, e := range v {
+			if k2, ok := k.(string); ok {
+				n[k2] = convertNodes(e)
+			}
+		}
+		return n
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convertNodes(e)
+		}
+	}
+	return val
+}
+
+func benchmarkDecode(b *testing.B, codec mc.Multicodec, dec mc.Multicodec, post bool) {
+	n := benchNode()
+	data, err := mc.Marshal(codec, &n)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v interface{}
+		if err := mc.Unmarshal(dec, data, &v); err != nil {
+			b.Fatal(err)
+		}
+		if post {
+			v = convertNodes(v)
+		}
+		if _, ok := v.(ipld.Node); !ok {
+			b.Fatalf("decoded %T", v)
+		}
+	}
+}
+
+func BenchmarkDecodeJson(b *testing.B) {
+	benchmarkDecode(b, JsonMulticodec(), JsonMulticodec(), false)
+}
+
+func BenchmarkDecodeJsonConvert(b *testing.B) {
+	benchmarkDecode(b, JsonMulticodec(), mcjson.Multicodec(false), true)
+}
+
+func BenchmarkDecodeCbor(b *testing.B) {
+	benchmarkDecode(b, CborMulticodec(), CborMulticodec(), false)
+}
+
+func BenchmarkDecodeCborConvert(b *testing.B) {
+	benchmarkDecode(b, CborMulticodec(), mccbor.Multicodec(), true)
+}
@@ -0,0 +1,565 @@
+package ipfsld
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	mc "github.com/jbenet/go-multicodec"
+	mcjson "github.com/jbenet/go-multicodec/json"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// JsonReservedKey is the key of the JSON objects which represent values
+// JSON lacks. Merkle-links without other properties are encoded as
+//
+//	{ "/": "<cid or base58 multihash>" }
+//
+// and byte strings as
+//
+//	{ "/": { "bytes": "<base64 without padding>" } }
+//
+// Only the exact key JsonReservedKey is reserved, other keys like "/a"
+// are kept as-is. Objects holding JsonReservedKey along with other keys
+// are invalid, and maps with a JsonReservedKey key cannot be encoded.
+const (
+	JsonReservedKey = "/"
+	JsonBytesTag    = "bytes"
+)
+
+var ErrInvalidReserved = errors.New("json: invalid object with reserved key")
+
+var linkType = reflect.TypeOf(ipld.Link{})
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+type jsonCodec struct{}
+
+type jsonEncoder struct {
+	w io.Writer
+}
+
+type jsonDecoder struct {
+	r *bufio.Reader
+}
+
+// JsonMulticodec returns a JSON multicodec. Values are decoded directly
+// into ipld.Node maps and []interface{} slices. Numbers with a fraction or
+// an exponent are decoded as ipld.Float, other numbers as ipld.Int, or
+// *big.Int if they do not fit in 64 bits.
+//
+// Maps are encoded with sorted keys, like encoding/json does. Byte strings
+// and ipld.Link values holding only a link are encoded in the forms
+// described by JsonReservedKey, and decoded back to []byte and ipld.Link.
+//
+// The reserved form is the canonical encoding of links, used for all the
+// ipld.Link values, including the ones decoded from CBOR tags or from the
+// reserved form itself. It is not used for ipld.Node maps with a "mlink"
+// key, such as the links decoded from CBOR maps: they are encoded as
+// objects, so existing nodes keep their bytes and hash, and user data
+// with a "mlink" key is not turned into a link. Links with other
+// properties are encoded as objects too. Floats are
+// always encoded with a fraction or an exponent, so they are decoded back
+// as ipld.Float.
+//
+// Other values, such as structs and json.Marshaler implementations, are
+// encoded with encoding/json. The byte strings and floats they hold do not
+// get the forms above: byte strings are encoded as base64 strings, and
+// integral floats as integers.
+func JsonMulticodec() mc.Multicodec {
+	return &jsonCodec{}
+}
+
+func (c *jsonCodec) Header() []byte {
+	return mcjson.Header
+}
+
+func (c *jsonCodec) Encoder(w io.Writer) mc.Encoder {
+	return &jsonEncoder{w}
+}
+
+func (c *jsonCodec) Decoder(r io.Reader) mc.Decoder {
+	return &jsonDecoder{bufio.NewReader(r)}
+}
+
+func (e *jsonEncoder) Encode(v interface{}) error {
+	var buf bytes.Buffer
+	buf.Write(mcjson.Header)
+	if err := encodeJson(&buf, reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func encodeJson(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) {
+		return encodeJsonValue(buf, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return encodeJson(buf, v.Elem())
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			buf.WriteString(`{"` + JsonReservedKey + `":{"` + JsonBytesTag + `":"`)
+			buf.WriteString(base64.RawStdEncoding.EncodeToString(b))
+			buf.WriteString(`"}}`)
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJson(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return encodeJsonValue(buf, v)
+		}
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Type() == linkType {
+			if s, ok := jsonLinkString(v.Interface().(ipld.Link)); ok {
+				buf.WriteString(`{"` + JsonReservedKey + `":`)
+				encodeJsonValue(buf, reflect.ValueOf(s))
+				buf.WriteByte('}')
+				return nil
+			}
+		}
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if k == JsonReservedKey {
+				return ErrInvalidReserved
+			}
+			if err := encodeJsonValue(buf, reflect.ValueOf(k)); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			kv := reflect.ValueOf(k).Convert(v.Type().Key())
+			if err := encodeJson(buf, v.MapIndex(kv)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	case reflect.Float32, reflect.Float64:
+		// encoding/json drops the fraction of integral floats.
+		b, err := ipld.Float(v.Float()).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+
+	default:
+		return encodeJsonValue(buf, v)
+	}
+	return nil
+}
+
+// jsonLinkString returns the target of l if it holds nothing else.
+func jsonLinkString(l ipld.Link) (string, bool) {
+	if len(l) != 1 {
+		return "", false
+	}
+	switch t := l[ipld.LinkKey].(type) {
+	case string:
+		return t, true
+	case ipld.Cid:
+		return t.String(), true
+	}
+	return "", false
+}
+
+// encodeJsonValue encodes v with encoding/json.
+func encodeJsonValue(buf *bytes.Buffer, v reflect.Value) error {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+func (d *jsonDecoder) Decode(v interface{}) error {
+	if err := mc.ConsumeHeader(d.r, mcjson.Header); err != nil {
+		return err
+	}
+
+	val, err := d.value()
+	if err != nil {
+		return err
+	}
+	return setDecoded(v, val)
+}
+
+func (d *jsonDecoder) syntaxError(c byte) error {
+	return fmt.Errorf("json: invalid character %q", c)
+}
+
+// next returns the next non-space character.
+func (d *jsonDecoder) next() (byte, error) {
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c, nil
+	}
+}
+
+func (d *jsonDecoder) value() (interface{}, error) {
+	c, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.valueFrom(c)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (d *jsonDecoder) valueFrom(c byte) (interface{}, error) {
+	switch {
+	case c == '{':
+		return d.object()
+	case c == '[':
+		return d.array()
+	case c == '"':
+		return d.str()
+	case c == 't':
+		return true, d.literal("rue")
+	case c == 'f':
+		return false, d.literal("alse")
+	case c == 'n':
+		return nil, d.literal("ull")
+	case c == '-' || (c >= '0' && c <= '9'):
+		return d.number(c)
+	}
+	return nil, d.syntaxError(c)
+}
+
+func (d *jsonDecoder) object() (interface{}, error) {
+	n := ipld.Node{}
+	reserved := false
+	c, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	if c == '}' {
+		return n, nil
+	}
+
+	for {
+		if c != '"' {
+			return nil, d.syntaxError(c)
+		}
+		k, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		if c, err = d.next(); err != nil {
+			return nil, err
+		} else if c != ':' {
+			return nil, d.syntaxError(c)
+		}
+		if k == JsonReservedKey {
+			reserved = true
+		}
+		if n[k], err = d.value(); err != nil {
+			return nil, err
+		}
+
+		if c, err = d.next(); err != nil {
+			return nil, err
+		}
+		switch c {
+		case '}':
+			if reserved {
+				return jsonReserved(n)
+			}
+			return n, nil
+		case ',':
+			if c, err = d.next(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, d.syntaxError(c)
+		}
+	}
+}
+
+// jsonReserved decodes an object holding JsonReservedKey to the link or
+// the byte string it represents.
+func jsonReserved(n ipld.Node) (interface{}, error) {
+	if len(n) != 1 {
+		return nil, ErrInvalidReserved
+	}
+
+	switch v := n[JsonReservedKey].(type) {
+	case string:
+		c, err := ipld.ParseCid(v)
+		if err != nil {
+			return nil, err
+		}
+		if c.Version == 0 {
+			return ipld.Link{ipld.LinkKey: v}, nil
+		}
+		return ipld.Link{ipld.LinkKey: c}, nil
+
+	case ipld.Node:
+		s, ok := v[JsonBytesTag].(string)
+		if !ok || len(v) != 1 {
+			return nil, ErrInvalidReserved
+		}
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	return nil, ErrInvalidReserved
+}
+
+func (d *jsonDecoder) array() (interface{}, error) {
+	s := []interface{}{}
+	c, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	if c == ']' {
+		return s, nil
+	}
+
+	for {
+		v, err := d.valueFrom(c)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+
+		if c, err = d.next(); err != nil {
+			return nil, err
+		}
+		switch c {
+		case ']':
+			return s, nil
+		case ',':
+			if c, err = d.next(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, d.syntaxError(c)
+		}
+	}
+}
+
+func (d *jsonDecoder) literal(rest string) error {
+	for i := 0; i < len(rest); i++ {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c != rest[i] {
+			return d.syntaxError(c)
+		}
+	}
+	return nil
+}
+
+func (d *jsonDecoder) number(first byte) (interface{}, error) {
+	buf := []byte{first}
+	for {
+		c, err := d.r.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if (c < '0' || c > '9') && c != '.' && c != 'e' && c != 'E' && c != '+' && c != '-' {
+			d.r.UnreadByte()
+			break
+		}
+		buf = append(buf, c)
+	}
+
+	return parseJsonNumber(string(buf))
+}
+
+// parseJsonNumber converts a JSON number literal to an ipld.Float if it
+// has a fraction or an exponent, or else to an ipld.Int or a *big.Int.
+func parseJsonNumber(s string) (interface{}, error) {
+	if !validJsonNumber(s) {
+		return nil, fmt.Errorf("json: invalid number %q", s)
+	}
+	if strings.ContainsAny(s, ".eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("json: invalid number %q", s)
+		}
+		return ipld.Float(f), nil
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ipld.Int(i), nil
+	}
+	if b, ok := new(big.Int).SetString(s, 10); ok && s[0] != '+' {
+		return b, nil
+	}
+	return nil, fmt.Errorf("json: invalid number %q", s)
+}
+
+// validJsonNumber returns whether s has the number syntax of RFC 8259,
+// which, unlike strconv, forbids leading zeros and requires digits after
+// the decimal point and in the exponent.
+func validJsonNumber(s string) bool {
+	i := 0
+	digits := func() int {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return i - start
+	}
+
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	if n := digits(); n == 0 || (n > 1 && s[i-n] == '0') {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+// str reads a string, after its opening quote.
+func (d *jsonDecoder) str() (string, error) {
+	var buf []byte
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case c == '"':
+			return string(buf), nil
+		case c < 0x20:
+			return "", d.syntaxError(c)
+		case c != '\\':
+			buf = append(buf, c)
+			continue
+		}
+
+		if c, err = d.r.ReadByte(); err != nil {
+			return "", err
+		}
+		switch c {
+		case '"', '\\', '/':
+			buf = append(buf, c)
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r, err := d.hex4()
+			if err != nil {
+				return "", err
+			}
+			if utf16.IsSurrogate(r) {
+				// like encoding/json, an escape which does not complete
+				// a surrogate pair is left to be read on its own.
+				r1 := r
+				r = utf8.RuneError
+				if next, _ := d.r.Peek(6); len(next) == 6 && string(next[:2]) == `\u` {
+					if r2, err := strconv.ParseUint(string(next[2:]), 16, 16); err == nil {
+						if dec := utf16.DecodeRune(r1, rune(r2)); dec != utf8.RuneError {
+							d.r.Discard(6)
+							r = dec
+						}
+					}
+				}
+			}
+			var rb [utf8.UTFMax]byte
+			buf = append(buf, rb[:utf8.EncodeRune(rb[:], r)]...)
+		default:
+			return "", d.syntaxError(c)
+		}
+	}
+}
+
+func (d *jsonDecoder) hex4() (rune, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(d.r, b[:]); err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(string(b[:]), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("json: invalid unicode escape %q", b[:])
+	}
+	return rune(n), nil
+}
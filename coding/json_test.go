@@ -0,0 +1,248 @@
+package ipfsld
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	mc "github.com/jbenet/go-multicodec"
+	mcjson "github.com/jbenet/go-multicodec/json"
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+	pb "github.com/ipfs/go-ipld/coding/pb"
+)
+
+func TestJsonDecode(t *testing.T) {
+	cases := []struct {
+		in  string
+		out interface{}
+	}{
+		{`{}`, ipld.Node{}},
+		{`[]`, []interface{}{}},
+		{` {"a" : [1, -2.5, 3e2, true, false, null], "b": {"c": ""}} `, ipld.Node{
+			"a": []interface{}{ipld.Int(1), ipld.Float(-2.5), ipld.Float(300), true, false, nil},
+			"b": ipld.Node{"c": ""},
+		}},
+		{`"a\"\\\/\b\f\n\r\t"`, "a\"\\/\b\f\n\r\t"},
+		{`"été 😀"`, "été 😀"},
+		{`[0, -0, 0.5, -0e1, 10]`, []interface{}{ipld.Int(0), ipld.Int(0), ipld.Float(0.5), ipld.Float(0), ipld.Int(10)}},
+		{`{"/foo": 1, "//": 2}`, ipld.Node{"/foo": ipld.Int(1), "//": ipld.Int(2)}},
+		{`"\ud83d"`, "�"},
+		{`"\ud83d\ude00"`, "😀"},
+		{`"\ud800\u0041"`, "\uFFFDA"},
+		{`"\ud800\ud800x"`, "\uFFFD\uFFFDx"},
+		{`"\ude00\ud83d\ude00"`, "\uFFFD😀"},
+	}
+
+	for _, c := range cases {
+		var v interface{}
+		data := append(append([]byte{}, mcjson.Header...), c.in+"\n"...)
+		if err := mc.Unmarshal(JsonMulticodec(), data, &v); err != nil {
+			t.Errorf("%s: %v", c.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, c.out) {
+			t.Errorf("%s: got %#v, expected %#v", c.in, v, c.out)
+		}
+	}
+}
+
+func TestJsonDecodeErrors(t *testing.T) {
+	invalid := []string{
+		``,
+		`{`,
+		`{"a"}`,
+		`{"a": 1,}`,
+		`{a: 1}`,
+		`[1 2]`,
+		`"abc`,
+		`"\x"`,
+		`"\u12"`,
+		`tru`,
+		`nul`,
+		`-`,
+		`1.2.3`,
+		`01`,
+		`-01`,
+		`00.5`,
+		`1.`,
+		`1e`,
+		`1e+`,
+	}
+
+	for _, in := range invalid {
+		var v interface{}
+		data := append(append([]byte{}, mcjson.Header...), in...)
+		if err := mc.Unmarshal(JsonMulticodec(), data, &v); err == nil {
+			t.Errorf("%s: expected an error, got %#v", in, v)
+		}
+	}
+}
+
+func TestJsonDecodeNode(t *testing.T) {
+	data := append(append([]byte{}, mcjson.Header...), "[1]\n"...)
+	var n ipld.Node
+	if err := mc.Unmarshal(JsonMulticodec(), data, &n); err != mc.ErrType {
+		t.Errorf("expected %v, got %v", mc.ErrType, err)
+	}
+}
+
+func TestJsonBytes(t *testing.T) {
+	n := ipld.Node{
+		"data":  []byte("hello"),
+		"empty": []byte{},
+		"list":  []interface{}{[]byte{0xff}},
+	}
+	data, err := mc.Marshal(JsonMulticodec(), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"data":{"/":{"bytes":"aGVsbG8"}},"empty":{"/":{"bytes":""}},"list":[{"/":{"bytes":"/w"}}]}` + "\n"
+	if string(data[len(mcjson.Header):]) != expected {
+		t.Errorf("expected %s, got %s", expected, data[len(mcjson.Header):])
+	}
+
+	var res ipld.Node
+	if err := mc.Unmarshal(JsonMulticodec(), data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, n) {
+		t.Errorf("expected %#v, got %#v", n, res)
+	}
+
+	// maps looking like byte strings cannot be told apart from them
+	other := ipld.Node{"/": ipld.Node{"bytes": "aGVsbG8", "x": "y"}}
+	if _, err = mc.Marshal(JsonMulticodec(), &other); err != ErrInvalidReserved {
+		t.Errorf("expected ErrInvalidReserved, got %v", err)
+	}
+}
+
+func TestJsonFloats(t *testing.T) {
+	n := ipld.Node{
+		"a": 1.0,
+		"b": float32(2),
+		"c": ipld.Float(-3),
+		"d": 4,
+		"e": []interface{}{1e21, 0.5},
+	}
+	data, err := mc.Marshal(JsonMulticodec(), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"a":1.0,"b":2.0,"c":-3.0,"d":4,"e":[1e+21,0.5]}` + "\n"
+	if string(data[len(mcjson.Header):]) != expected {
+		t.Errorf("expected %s, got %s", expected, data[len(mcjson.Header):])
+	}
+
+	var res ipld.Node
+	if err := mc.Unmarshal(JsonMulticodec(), data, &res); err != nil {
+		t.Fatal(err)
+	}
+	decoded := ipld.Node{
+		"a": ipld.Float(1),
+		"b": ipld.Float(2),
+		"c": ipld.Float(-3),
+		"d": ipld.Int(4),
+		"e": []interface{}{ipld.Float(1e21), ipld.Float(0.5)},
+	}
+	if !reflect.DeepEqual(res, decoded) {
+		t.Errorf("expected %#v, got %#v", decoded, res)
+	}
+}
+
+// Protobuf nodes hold byte strings, and are encoded back to the same bytes
+// after a conversion to JSON.
+func TestJsonProtobufRoundtrip(t *testing.T) {
+	pbdata, err := ioutil.ReadFile("pb/testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n ipld.Node
+	if err := mc.Unmarshal(pb.Multicodec(), pbdata, &n); err != nil {
+		t.Fatal(err)
+	}
+	data, err := mc.Marshal(JsonMulticodec(), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res ipld.Node
+	if err := mc.Unmarshal(JsonMulticodec(), data, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := mc.Marshal(pb.Multicodec(), &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, pbdata) {
+		t.Errorf("protobuf node changed after a JSON round trip")
+	}
+}
+
+// Links held in ipld.Link values use the reserved form described by
+// JsonReservedKey, other maps with a "mlink" key keep the map form of
+// json.testfile. Only the "/" key is reserved.
+func TestJsonLinks(t *testing.T) {
+	h, err := ipld.Sum([]byte("block"), mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ipld.NewCid(ipld.CodecCBOR, h)
+
+	n := ipld.Node{
+		"a":  ipld.Link{"mlink": tokenHashA},
+		"b":  ipld.LinkToCid(c),
+		"c":  ipld.Node{"mlink": tokenHashA},
+		"d":  ipld.Link{"mlink": tokenHashA, "name": "d"},
+		"/e": ipld.Node{"//": "not a link"},
+	}
+	data, err := mc.Marshal(JsonMulticodec(), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"/e":{"//":"not a link"},` +
+		`"a":{"/":"` + tokenHashA + `"},` +
+		`"b":{"/":"` + c.String() + `"},` +
+		`"c":{"mlink":"` + tokenHashA + `"},` +
+		`"d":{"mlink":"` + tokenHashA + `","name":"d"}}` + "\n"
+	if string(data[len(mcjson.Header):]) != expected {
+		t.Errorf("expected %s, got %s", expected, data[len(mcjson.Header):])
+	}
+
+	var res ipld.Node
+	if err := mc.Unmarshal(JsonMulticodec(), data, &res); err != nil {
+		t.Fatal(err)
+	}
+	// links with other properties are decoded as nodes
+	n["d"] = ipld.Node(n["d"].(ipld.Link))
+	if !reflect.DeepEqual(res, n) {
+		t.Errorf("expected %#v, got %#v", n, res)
+	}
+	if links := ipld.Links(res); len(links) != 4 {
+		t.Errorf("expected 4 links, got %#v", links)
+	}
+
+	invalid := []string{
+		`{"/":1}`,
+		`{"/":"` + tokenHashA + `","a":1}`,
+		`{"/":"not a cid"}`,
+		`{"/":{"bytes":"!"}}`,
+		`{"/":{"bytes":"","x":1}}`,
+	}
+	for _, in := range invalid {
+		var v interface{}
+		data := append(append([]byte{}, mcjson.Header...), in...)
+		if err := mc.Unmarshal(JsonMulticodec(), data, &v); err == nil {
+			t.Errorf("%s: expected an error, got %#v", in, v)
+		}
+	}
+
+	// user maps with the reserved key cannot be encoded
+	user := ipld.Node{"/": tokenHashA}
+	if _, err := mc.Marshal(JsonMulticodec(), &user); err != ErrInvalidReserved {
+		t.Errorf("expected ErrInvalidReserved, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 
 	mc "github.com/jbenet/go-multicodec"
 	mcproto "github.com/jbenet/go-multicodec/protobuf"
@@ -116,12 +117,23 @@ func ld2pbNode(in *ipld.Node) (*PBNode, error) {
 	}
 
 	if links, haslinks := attrs["links"]; haslinks {
-		links, ok := links.([]ipld.Node)
-		if !ok {
+		var nodes []ipld.Node
+		switch links := links.(type) {
+		case []ipld.Node:
+			nodes = links
+		case []interface{}: // as decoded by other codecs
+			for _, link := range links {
+				ln, ok := link.(ipld.Node)
+				if !ok {
+					return nil, errInvalidLink
+				}
+				nodes = append(nodes, ln)
+			}
+		default:
 			return nil, errInvalidLink
 		}
 
-		for _, link := range links {
+		for _, link := range nodes {
 			pblink := ld2pbLink(link)
 			if pblink == nil {
 				return nil, fmt.Errorf("%s (%s)", errInvalidLink, link["name"])
@@ -158,7 +170,7 @@ func pb2ldLink(pbl *PBLink) (link ipld.Node) {
 	link = make(ipld.Node)
 	link["hash"] = pbl.Hash
 	link["name"] = *pbl.Name
-	link["size"] = uint64(*pbl.Tsize)
+	link["size"] = ipld.Uint(*pbl.Tsize)
 	return link
 }
 
@@ -171,15 +183,32 @@ func ld2pbLink(link ipld.Node) (pbl *PBLink) {
 
 	hash := link["hash"].([]byte)
 	name := link["name"].(string)
-	size := link["size"].(uint64)
+	tsize, ok := toUint64(link["size"])
+	if !ok {
+		return nil
+	}
 
 	pbl = &PBLink{}
 	pbl.Hash = hash
 	pbl.Name = &name
-	pbl.Tsize = &size
+	pbl.Tsize = &tsize
 	return pbl
 }
 
+// toUint64 converts the size of a link to a Tsize. It accepts the non
+// negative integers of the data model, including the *big.Int values
+// pb2ldLink produces for sizes above math.MaxInt64, and native integers.
+func toUint64(v interface{}) (uint64, bool) {
+	n, _ := ipld.Number(v)
+	switch n := n.(type) {
+	case ipld.Int:
+		return uint64(n), n >= 0
+	case *big.Int:
+		return n.Uint64(), n.Sign() >= 0 && n.BitLen() <= 64
+	}
+	return 0, false
+}
+
 func IsOldProtobufNode(n ipld.Node) bool {
 	if len(n) > 2 { // short circuit
 		return false
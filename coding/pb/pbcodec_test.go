@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"encoding/hex"
 	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
 	"testing"
 	"reflect"
 
@@ -119,7 +122,7 @@ func TestPB2LD(t *testing.T) {
 	if makefile == nil {
 		t.Error("did not find Makefile")
 	} else {
-		size, ok := makefile["size"].(uint64)
+		size, ok := makefile["size"].(ipld.Int)
 		if !ok {
 			t.Log(makefile)
 			t.Fatal("invalid ipld.links[makefile].size")
@@ -156,3 +159,71 @@ func TestLD2PB(t *testing.T) {
 		t.Fatal("decoded bytes != encoded bytes")
 	}
 }
+
+func TestLargeTsize(t *testing.T) {
+	name := "large"
+	for _, tsize := range []uint64{0, 1 << 62, math.MaxUint64} {
+		size := tsize
+		pbl := &PBLink{Hash: []byte{1, 2, 3}, Name: &name, Tsize: &size}
+		res := ld2pbLink(pb2ldLink(pbl))
+		if res == nil {
+			t.Errorf("%d: link not converted back", tsize)
+			continue
+		}
+		if *res.Tsize != tsize {
+			t.Errorf("expected %d, got %d", tsize, *res.Tsize)
+		}
+	}
+
+	link := ipld.Node{"hash": []byte{1}, "name": name}
+	for _, size := range []interface{}{uint32(12), ipld.Int(12)} {
+		link["size"] = size
+		if pbl := ld2pbLink(link); pbl == nil || *pbl.Tsize != 12 {
+			t.Errorf("%T: expected size 12, got %v", size, pbl)
+		}
+	}
+	for _, size := range []interface{}{ipld.Int(-1), new(big.Int).Lsh(big.NewInt(1), 64), ipld.Float(1)} {
+		link["size"] = size
+		if pbl := ld2pbLink(link); pbl != nil {
+			t.Errorf("%v: expected an invalid link, got %v", size, pbl)
+		}
+	}
+}
+
+// The links of decoded nodes are held in a []ipld.Node, which traversals
+// must descend into.
+func TestPBTraversal(t *testing.T) {
+	var n ipld.Node
+	if err := mc.Unmarshal(Multicodec(), testfile, &n); err != nil {
+		t.Fatal("failed to decode", err)
+	}
+	links := n["@attrs"].(ipld.Node)["links"].([]ipld.Node)
+
+	visited := map[string]bool{}
+	_, err := ipld.Transform(n, func(root, curr ipld.Node, path ipld.Path, err error) (ipld.Node, error) {
+		visited[path.String()] = true
+		return nil, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, link := range links {
+		if p := "@attrs/links/" + strconv.Itoa(i); !visited[p] {
+			t.Errorf("link %s not visited at %s", link["name"], p)
+		}
+	}
+
+	count := 0
+	ipld.Walk(ipld.Node{"files": links}, func(root, curr ipld.Node, path ipld.Path, err error) error {
+		count++
+		return err
+	})
+	if count != len(links)+1 {
+		t.Errorf("expected %d nodes, walked %d", len(links)+1, count)
+	}
+
+	name := links[0]["name"].(string)
+	if v := ipld.GetPath(ipld.Node{"files": links}, "/files/0/name"); v != name {
+		t.Errorf("expected %q, got %#v", name, v)
+	}
+}
@@ -0,0 +1,86 @@
+package ipfsld
+
+import (
+	mc "github.com/jbenet/go-multicodec"
+	mccbor "github.com/jbenet/go-multicodec/cbor"
+	mcjson "github.com/jbenet/go-multicodec/json"
+
+	ipld "github.com/ipfs/go-ipld"
+	pb "github.com/ipfs/go-ipld/coding/pb"
+)
+
+// Coder is implemented by codecs which have a multicodec code, the code
+// used in Cids to tell the serialization of blocks.
+type Coder interface {
+	Code() uint64
+}
+
+// knownCodes are the codes of the codecs which do not implement Coder,
+// by header path.
+var knownCodes = map[string]uint64{
+	string(mc.HeaderPath(mccbor.Header)): ipld.CodecCBOR,
+	string(mc.HeaderPath(mcjson.Header)): ipld.CodecJSON,
+	string(mc.HeaderPath(pb.Header)):     ipld.CodecProtobuf,
+}
+
+// registered holds the codecs of the muxing codec, in registration order.
+var registered []mc.Multicodec
+
+// Register adds c to the codecs used by the muxing codec returned by
+// Multicodec. Nodes are encoded with c when their "@codec" is the path of
+// its header, and blocks starting with its header are decoded with c. A
+// codec registered with the same header as a previous one replaces it.
+//
+// The CBOR, JSON and protobuf codecs are registered by default. Register
+// is meant to be called from init functions: it must not be called while
+// nodes are encoded or decoded.
+func Register(c mc.Multicodec) {
+	path := string(mc.HeaderPath(c.Header()))
+	for i, rc := range registered {
+		if string(mc.HeaderPath(rc.Header())) == path {
+			registered[i] = c
+			return
+		}
+	}
+	registered = append(registered, c)
+	if muxCodec != nil {
+		muxCodec.Codecs = registered
+	}
+}
+
+// Codecs returns the registered codecs.
+func Codecs() []mc.Multicodec {
+	return append([]mc.Multicodec{}, registered...)
+}
+
+// CodecByPath returns the registered codec whose header path is path,
+// like "/cbor", or nil.
+func CodecByPath(path string) mc.Multicodec {
+	for _, c := range registered {
+		if string(mc.HeaderPath(c.Header())) == path {
+			return c
+		}
+	}
+	return nil
+}
+
+// CodecByCode returns the registered codec whose multicodec code is code,
+// or nil. The code of a codec is given by its Code method if it
+// implements Coder.
+func CodecByCode(code uint64) mc.Multicodec {
+	for _, c := range registered {
+		if cc, ok := CodecCode(c); ok && cc == code {
+			return c
+		}
+	}
+	return nil
+}
+
+// CodecCode returns the multicodec code of c, if it is known.
+func CodecCode(c mc.Multicodec) (uint64, bool) {
+	if cc, ok := c.(Coder); ok {
+		return cc.Code(), true
+	}
+	code, ok := knownCodes[string(mc.HeaderPath(c.Header()))]
+	return code, ok
+}
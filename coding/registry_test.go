@@ -0,0 +1,105 @@
+package ipfsld
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	mc "github.com/jbenet/go-multicodec"
+	mccbor "github.com/jbenet/go-multicodec/cbor"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// upperCodec is a custom codec, which stores nodes as JSON with upper case
+// keys.
+type upperCodec struct{}
+
+var upperHeader = mc.Header([]byte("/test-upper"))
+
+func (upperCodec) Header() []byte { return upperHeader }
+func (upperCodec) Code() uint64   { return 0x300000 }
+
+func (upperCodec) Encoder(w io.Writer) mc.Encoder { return &upperEncoder{w} }
+func (upperCodec) Decoder(r io.Reader) mc.Decoder { return &upperDecoder{r} }
+
+type upperEncoder struct{ w io.Writer }
+type upperDecoder struct{ r io.Reader }
+
+func (e *upperEncoder) Encode(v interface{}) error {
+	up := ipld.Node{}
+	for k, e := range *v.(*ipld.Node) {
+		up[strings.ToUpper(k)] = e
+	}
+	data, err := json.Marshal(up)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(append([]byte{}, upperHeader...), data...))
+	return err
+}
+
+func (d *upperDecoder) Decode(v interface{}) error {
+	if err := mc.ConsumeHeader(d.r, upperHeader); err != nil {
+		return err
+	}
+	var up map[string]interface{}
+	if err := json.NewDecoder(d.r).Decode(&up); err != nil {
+		return err
+	}
+	n := ipld.Node{}
+	for k, e := range up {
+		n[strings.ToLower(k)] = e
+	}
+	*v.(*ipld.Node) = n
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	if c := CodecByPath("/test-upper"); c != nil {
+		t.Fatalf("unexpected codec %#v", c)
+	}
+
+	defer saveRegistry()()
+	Register(upperCodec{})
+
+	if c := CodecByPath("/test-upper"); c != (upperCodec{}) {
+		t.Errorf("expected the registered codec, got %#v", c)
+	}
+	if c := CodecByCode(0x300000); c != (upperCodec{}) {
+		t.Errorf("expected the registered codec, got %#v", c)
+	}
+	if c := CodecByCode(ipld.CodecCBOR); c == nil || !bytes.Equal(c.Header(), mccbor.Header) {
+		t.Errorf("expected the CBOR codec, got %#v", c)
+	}
+
+	n := ipld.Node{"@codec": "/test-upper", "foo": "bar"}
+	data, err := mc.Marshal(Multicodec(), &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"FOO":"bar"`)) {
+		t.Errorf("not encoded with the registered codec: %q", data)
+	}
+
+	var res ipld.Node
+	if err := mc.Unmarshal(Multicodec(), data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, n) {
+		t.Errorf("expected %#v, got %#v", n, res)
+	}
+}
+
+// saveRegistry returns a function restoring the registered codecs, so the
+// tests registering codecs can be run again.
+func saveRegistry() func() {
+	saved := append([]mc.Multicodec{}, registered...)
+	return func() {
+		registered = saved
+		muxCodec.Codecs = registered
+	}
+}
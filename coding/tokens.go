@@ -0,0 +1,587 @@
+package ipfsld
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	mc "github.com/jbenet/go-multicodec"
+	mccbor "github.com/jbenet/go-multicodec/cbor"
+	mcjson "github.com/jbenet/go-multicodec/json"
+	mcmux "github.com/jbenet/go-multicodec/mux"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// TokenType is the type of a Token.
+type TokenType int
+
+const (
+	TokenMapStart  TokenType = iota // start of a map, followed by keys and values
+	TokenMapEnd                     // end of a map
+	TokenListStart                  // start of a list, followed by its items
+	TokenListEnd                    // end of a list
+	TokenKey                        // map key, Value is usually a string
+	TokenValue                      // scalar value
+	TokenLink                       // merkle-link target, Value is an ipld.Cid
+)
+
+var tokenNames = []string{"map start", "map end", "list start", "list end", "key", "value", "link"}
+
+func (t TokenType) String() string {
+	if int(t) < len(tokenNames) {
+		return tokenNames[t]
+	}
+	return "token " + strconv.Itoa(int(t))
+}
+
+// Token is an element of the stream of tokens read by a TokenReader.
+//
+// Scalar values are nil, bool, ipld.Int, ipld.Float, *big.Int, string or
+// []byte, as in decoded nodes. Length is the number of entries of maps and
+// lists at their start, or -1 if it is not known in advance.
+type Token struct {
+	Type   TokenType
+	Value  interface{}
+	Length int
+}
+
+// TokenReader reads a serialized node as a stream of tokens, without
+// building the node in memory.
+//
+// Links encoded as maps, like { "mlink": "Qm..." }, are read as a
+// TokenMapStart, a TokenKey for "mlink", a TokenLink and the other
+// properties of the link. Links encoded as CBOR tags (see
+// LinkTagCborMulticodec) or in the JSON reserved form (see
+// JsonReservedKey) are read as a single TokenLink.
+type TokenReader interface {
+	// ReadToken returns the next token, or io.EOF at the end of the
+	// value.
+	ReadToken() (Token, error)
+
+	// Skip skips the next value. If the next token starts a map or a
+	// list, the whole container is skipped.
+	Skip() error
+}
+
+var ErrUnexpectedToken = errors.New("unexpected token")
+
+// NewTokenReader returns a TokenReader for data serialized with the CBOR or
+// JSON codec, prefixed with the codec header and optionally with the
+// muxing codec header.
+func NewTokenReader(r io.Reader) (TokenReader, error) {
+	hdr, err := mc.ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if string(hdr) == string(mcmux.Header) {
+		if hdr, err = mc.ReadHeader(r); err != nil {
+			return nil, err
+		}
+	}
+
+	switch string(hdr) {
+	case string(mccbor.Header):
+		return NewCborTokenReader(r), nil
+	case string(mcjson.Header):
+		return NewJsonTokenReader(r), nil
+	}
+	return nil, fmt.Errorf("no token reader for codec %s", mc.HeaderPath(hdr))
+}
+
+// tokenFrame is an open container.
+type tokenFrame struct {
+	isMap   bool
+	indef   bool
+	left    uint64 // remaining items of definite containers
+	inValue bool   // a key was read, its value is next
+}
+
+// tokenState tracks the position in the stream, shared by both readers.
+type tokenState struct {
+	stack    []tokenFrame
+	started  bool
+	linkNext bool // the next value is the value of an "mlink" key
+}
+
+func (s *tokenState) done() bool {
+	return s.started && len(s.stack) == 0
+}
+
+// item records that an item starts, and returns whether it is a map key.
+func (s *tokenState) item() bool {
+	s.started = true
+	if len(s.stack) == 0 {
+		return false
+	}
+	f := &s.stack[len(s.stack)-1]
+	if !f.indef {
+		f.left--
+	}
+	if !f.isMap {
+		return false
+	}
+	f.inValue = !f.inValue
+	return f.inValue
+}
+
+// scalar returns the token of a scalar value.
+func (s *tokenState) scalar(v interface{}, key bool) Token {
+	if key {
+		s.linkNext = v == ipld.LinkKey
+		return Token{Type: TokenKey, Value: v}
+	}
+
+	link := s.linkNext
+	s.linkNext = false
+	switch lv := v.(type) {
+	case string:
+		if link {
+			if c, err := ipld.ParseCid(lv); err == nil {
+				return Token{Type: TokenLink, Value: c}
+			}
+		}
+	case ipld.Cid:
+		// tagged Cids are links, whether they are the value of an
+		// "mlink" key or not.
+		return Token{Type: TokenLink, Value: lv}
+	}
+	return Token{Type: TokenValue, Value: v}
+}
+
+func (s *tokenState) push(isMap, indef bool, n uint64) Token {
+	s.linkNext = false
+	s.stack = append(s.stack, tokenFrame{isMap: isMap, indef: indef, left: n})
+	length := int(n)
+	if indef {
+		length = -1
+	}
+	if isMap {
+		s.stack[len(s.stack)-1].left *= 2
+		return Token{Type: TokenMapStart, Length: length}
+	}
+	return Token{Type: TokenListStart, Length: length}
+}
+
+func (s *tokenState) pop() Token {
+	f := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	if f.isMap {
+		return Token{Type: TokenMapEnd}
+	}
+	return Token{Type: TokenListEnd}
+}
+
+// skip reads the next value from tr.
+func skip(tr TokenReader) error {
+	t, err := tr.ReadToken()
+	if err != nil {
+		return err
+	}
+	if t.Type == TokenMapEnd || t.Type == TokenListEnd || t.Type == TokenKey {
+		return ErrUnexpectedToken
+	}
+	return skipFrom(tr, t)
+}
+
+// skipFrom skips the rest of the value starting with t.
+func skipFrom(tr TokenReader, t Token) error {
+	depth := 0
+	for {
+		switch t.Type {
+		case TokenMapStart, TokenListStart:
+			depth++
+		case TokenMapEnd, TokenListEnd:
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+
+		var err error
+		if t, err = tr.ReadToken(); err != nil {
+			return err
+		}
+	}
+}
+
+type cborTokenReader struct {
+	tokenState
+	r io.Reader
+}
+
+// NewCborTokenReader returns a TokenReader for CBOR data, without header.
+func NewCborTokenReader(r io.Reader) TokenReader {
+	return &cborTokenReader{r: r}
+}
+
+func (t *cborTokenReader) ReadToken() (Token, error) {
+	if n := len(t.stack); n > 0 {
+		if f := t.stack[n-1]; !f.indef && f.left == 0 {
+			return t.pop(), nil
+		}
+	} else if t.done() {
+		return Token{}, io.EOF
+	}
+
+	major, info, n, indef, err := readCborHead(t.r)
+	if err != nil {
+		return Token{}, err
+	}
+
+	if major == cborSimple && info == cborIndefinite {
+		if len(t.stack) == 0 {
+			return Token{}, errBreak
+		}
+		f := t.stack[len(t.stack)-1]
+		if !f.indef || f.inValue {
+			return Token{}, errBreak
+		}
+		return t.pop(), nil
+	}
+
+	key := t.item()
+
+	// skip tags, except the ones of links and bignums, which are decoded
+	// like decodeCborItem does
+	for major == cborTag && n != CborTagCid && n != CborTagPosBignum && n != CborTagNegBignum {
+		if major, info, n, indef, err = readCborHead(t.r); err != nil {
+			return Token{}, err
+		}
+	}
+
+	switch {
+	case key && (major == cborArray || major == cborMap):
+		v, err := decodeCborItem(t.r, major, info, n, indef, CborKeysDrop)
+		return Token{Type: TokenKey, Value: v}, err
+	case major == cborArray:
+		return t.push(false, indef, n), nil
+	case major == cborMap:
+		return t.push(true, indef, n), nil
+	}
+
+	v, err := decodeCborItem(t.r, major, info, n, indef, CborKeysDrop)
+	if err != nil {
+		return Token{}, err
+	}
+	return t.scalar(v, key), nil
+}
+
+func (t *cborTokenReader) Skip() error {
+	return skip(t)
+}
+
+type jsonTokenReader struct {
+	tokenState
+	dec     *json.Decoder
+	pending json.Token // token read ahead after the start of an object
+}
+
+// NewJsonTokenReader returns a TokenReader for JSON data, without header.
+// Numbers and the objects described by JsonReservedKey are read as with
+// the JSON codec: links are read as a single TokenLink, and byte strings
+// as a TokenValue.
+func NewJsonTokenReader(r io.Reader) TokenReader {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &jsonTokenReader{dec: dec}
+}
+
+func (t *jsonTokenReader) ReadToken() (Token, error) {
+	if t.done() {
+		return Token{}, io.EOF
+	}
+
+	jt, err := t.next()
+	if err != nil {
+		return Token{}, err
+	}
+
+	if d, ok := jt.(json.Delim); ok && (d == '}' || d == ']') {
+		return t.pop(), nil
+	}
+
+	key := t.item()
+	if d, ok := jt.(json.Delim); ok {
+		if d == '{' {
+			if tok, ok, err := t.reserved(); ok || err != nil {
+				return tok, err
+			}
+		}
+		return t.push(d == '{', true, 0), nil
+	}
+	if num, ok := jt.(json.Number); ok {
+		if jt, err = parseJsonNumber(string(num)); err != nil {
+			return Token{}, err
+		}
+	}
+	if key && jt == JsonReservedKey {
+		return Token{}, ErrInvalidReserved // along with other keys
+	}
+	return t.scalar(jt, key), nil
+}
+
+// next returns the token read ahead, or the next token of the decoder.
+func (t *jsonTokenReader) next() (json.Token, error) {
+	if jt := t.pending; jt != nil {
+		t.pending = nil
+		return jt, nil
+	}
+	jt, err := t.dec.Token()
+	if err == io.EOF && t.started {
+		err = io.ErrUnexpectedEOF
+	}
+	return jt, err
+}
+
+// reserved reads the first key of an object, after its opening brace. If
+// it is JsonReservedKey, the whole object is read and returned as the
+// token of the link or the byte string it represents, and ok is true.
+// Otherwise the key is kept for the next call to ReadToken.
+func (t *jsonTokenReader) reserved() (tok Token, ok bool, err error) {
+	jt, err := t.next()
+	if err != nil {
+		return Token{}, false, err
+	}
+	if k, isString := jt.(string); !isString || k != JsonReservedKey {
+		t.pending = jt
+		return Token{}, false, nil
+	}
+
+	var raw json.RawMessage
+	if err := t.dec.Decode(&raw); err != nil {
+		return Token{}, false, err
+	}
+	if jt, err = t.next(); err != nil {
+		return Token{}, false, err
+	} else if jt != json.Delim('}') {
+		return Token{}, false, ErrInvalidReserved
+	}
+
+	d := &jsonDecoder{bufio.NewReader(bytes.NewReader(raw))}
+	v, err := d.value()
+	if err != nil {
+		return Token{}, false, err
+	}
+	if v, err = jsonReserved(ipld.Node{JsonReservedKey: v}); err != nil {
+		return Token{}, false, err
+	}
+	if l, isLink := v.(ipld.Link); isLink {
+		c, _ := linkCid(l)
+		return t.scalar(c, false), true, nil
+	}
+	return t.scalar(v, false), true, nil
+}
+
+func (t *jsonTokenReader) Skip() error {
+	return skip(t)
+}
+
+// ReadValue reads the next value from tr and returns it as it would be
+// decoded by the codec: maps are ipld.Node and lists are []interface{}.
+// Links encoded as tags are returned as ipld.Link values.
+func ReadValue(tr TokenReader) (interface{}, error) {
+	t, err := tr.ReadToken()
+	if err != nil {
+		return nil, err
+	}
+	return readValue(tr, t)
+}
+
+func readValue(tr TokenReader, t Token) (interface{}, error) {
+	switch t.Type {
+	case TokenValue:
+		return t.Value, nil
+
+	case TokenLink:
+		return cidLink(t.Value.(ipld.Cid)), nil
+
+	case TokenListStart:
+		s := []interface{}{}
+		for {
+			t, err := tr.ReadToken()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == TokenListEnd {
+				return s, nil
+			}
+			v, err := readValue(tr, t)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+
+	case TokenMapStart:
+		n := ipld.Node{}
+		for {
+			t, err := tr.ReadToken()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == TokenMapEnd {
+				return n, nil
+			}
+			if t.Type != TokenKey {
+				return nil, ErrUnexpectedToken
+			}
+
+			k, isString := t.Value.(string)
+			vt, err := tr.ReadToken()
+			if err != nil {
+				return nil, err
+			}
+			if vt.Type == TokenLink && isString && k == ipld.LinkKey {
+				n[k] = linkValue(vt.Value.(ipld.Cid))
+				continue
+			}
+			v, err := readValue(tr, vt)
+			if err != nil {
+				return nil, err
+			}
+			if isString {
+				n[k] = v
+			}
+		}
+	}
+	return nil, ErrUnexpectedToken
+}
+
+// linkValue returns the value of the "mlink" key of a link to c.
+func linkValue(c ipld.Cid) interface{} {
+	if c.Version == 0 {
+		return c.String()
+	}
+	return c
+}
+
+// ReadPath reads the value at path in the node read from tr, skipping the
+// other values. It returns nil if there is no such value. Indexes may be
+// used in path to select list items. Links are not followed. Like
+// ipld.GetPathCmp, map keys are escaped with ipld.EscapePathComponent before
+// being compared to the keys read from tr.
+func ReadPath(tr TokenReader, path ipld.Path) (interface{}, error) {
+	t, err := tr.ReadToken()
+	if err != nil {
+		return nil, err
+	}
+	return readPath(tr, t, path)
+}
+
+func readPath(tr TokenReader, t Token, path ipld.Path) (interface{}, error) {
+	if len(path) == 0 {
+		return readValue(tr, t)
+	}
+
+	switch t.Type {
+	case TokenMapStart:
+		key := ipld.EscapePathComponent(path[0])
+		for {
+			t, err := tr.ReadToken()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == TokenMapEnd {
+				return nil, nil
+			}
+			if t.Value == key {
+				return ReadPath(tr, path[1:])
+			}
+			if err := tr.Skip(); err != nil {
+				return nil, err
+			}
+		}
+
+	case TokenListStart:
+		index, err := strconv.Atoi(path[0])
+		if err != nil {
+			return nil, nil
+		}
+		for i := 0; ; i++ {
+			t, err := tr.ReadToken()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == TokenListEnd {
+				return nil, nil
+			}
+			if i == index {
+				return readPath(tr, t, path[1:])
+			}
+			if err := skipFrom(tr, t); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// ReadLinks returns all the links in the node read from tr, keyed by the
+// string form of their path like ipld.Links. Only the hash of the links is
+// returned, not their other properties. Like ipld.Links, the values of
+// directive keys such as "@attrs" are skipped, and "mlink" values which
+// are not valid Cids are still returned as links.
+func ReadLinks(tr TokenReader) (map[string]ipld.Link, error) {
+	links := map[string]ipld.Link{}
+	var path ipld.Path
+	var index []int // index of the next item of open lists, -1 for maps
+
+	for {
+		t, err := tr.ReadToken()
+		if err == io.EOF {
+			return links, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		inList := len(index) > 0 && index[len(index)-1] >= 0
+		if inList && t.Type != TokenListEnd {
+			path = append(path, strconv.Itoa(index[len(index)-1]))
+			index[len(index)-1]++
+		}
+
+		switch t.Type {
+		case TokenKey:
+			k, _ := t.Value.(string)
+			if k == "" || k[0] == '@' {
+				// skipped like ipld.Walk does, "@" is for directives
+				if err := tr.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			path = append(path, ipld.UnescapePathComponent(k))
+			continue
+		case TokenLink:
+			p := path
+			if len(p) > 0 && p[len(p)-1] == ipld.LinkKey {
+				p = p.Parent() // link encoded as a map
+			}
+			links[p.String()] = ipld.Link{ipld.LinkKey: linkValue(t.Value.(ipld.Cid))}
+		case TokenValue:
+			// "mlink" values which are not Cids are still links, as
+			// for ipld.IsLink
+			if s, ok := t.Value.(string); ok && len(path) > 0 && path[len(path)-1] == ipld.LinkKey {
+				links[path.Parent().String()] = ipld.Link{ipld.LinkKey: s}
+			}
+		case TokenMapStart:
+			index = append(index, -1)
+			continue
+		case TokenListStart:
+			index = append(index, 0)
+			continue
+		case TokenMapEnd, TokenListEnd:
+			index = index[:len(index)-1]
+		}
+
+		// end of a value
+		if len(path) > 0 {
+			path = path[:len(path)-1]
+		}
+	}
+}
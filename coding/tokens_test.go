@@ -0,0 +1,290 @@
+package ipfsld
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	mc "github.com/jbenet/go-multicodec"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+const (
+	tokenHashA = "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"
+	tokenHashB = "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPb"
+)
+
+var tokenNode = ipld.Node{
+	"title": "big directory",
+	"size":  ipld.Int(3),
+	"entries": []interface{}{
+		ipld.Node{"name": "a", "file": ipld.Node{"mlink": tokenHashA}},
+		ipld.Node{"name": "b", "file": ipld.Node{"mlink": tokenHashB, "size": ipld.Int(12)}},
+	},
+	"parent": ipld.Link{"mlink": tokenHashA},
+	"data":   []byte("hello"),
+	"/type":  "escaped",
+}
+
+func TestTokenReaderValue(t *testing.T) {
+	codecs := []mc.Multicodec{
+		CanonicalCborMulticodec(),
+		LinkTagCborMulticodec(),
+		JsonMulticodec(),
+		Multicodec(),
+	}
+	for _, codec := range codecs {
+		n := tokenNode
+		data, err := mc.Marshal(codec, &n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected ipld.Node
+		if err := mc.Unmarshal(codec, data, &expected); err != nil {
+			t.Fatal(err)
+		}
+
+		tr, err := NewTokenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := ReadValue(tr)
+		if err != nil {
+			t.Fatalf("%s: %s", codec.Header(), err)
+		}
+		if !reflect.DeepEqual(v, expected) {
+			t.Errorf("%s: expected %#v, got %#v", codec.Header(), expected, v)
+		}
+		if _, err := tr.ReadToken(); err != io.EOF {
+			t.Errorf("%s: expected EOF, got %v", codec.Header(), err)
+		}
+	}
+}
+
+func TestTokenReaderTokens(t *testing.T) {
+	c, err := ipld.ParseCid(tokenHashA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := ipld.Node{"a": []interface{}{ipld.Int(1), ipld.Node{"mlink": tokenHashA}}}
+	expected := []Token{
+		{Type: TokenMapStart, Length: 1},
+		{Type: TokenKey, Value: "a"},
+		{Type: TokenListStart, Length: 2},
+		{Type: TokenValue, Value: ipld.Int(1)},
+		{Type: TokenMapStart, Length: 1},
+		{Type: TokenKey, Value: "mlink"},
+		{Type: TokenLink, Value: c},
+		{Type: TokenMapEnd},
+		{Type: TokenListEnd},
+		{Type: TokenMapEnd},
+	}
+
+	data, err := mc.Marshal(CanonicalCborMulticodec(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := NewTokenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range expected {
+		tok, err := tr.ReadToken()
+		if err != nil {
+			t.Fatalf("token %d: %s", i, err)
+		}
+		if !reflect.DeepEqual(tok, e) {
+			t.Errorf("token %d: expected %#v, got %#v", i, e, tok)
+		}
+	}
+
+	// indefinite lengths, {_ "a": 1, "b": [_ 2, 3]}
+	data, _ = hex.DecodeString("bf61610161629f0203ffff")
+	tr = NewCborTokenReader(bytes.NewReader(data))
+	types := []TokenType{TokenMapStart, TokenKey, TokenValue, TokenKey, TokenListStart, TokenValue, TokenValue, TokenListEnd, TokenMapEnd}
+	for i, e := range types {
+		tok, err := tr.ReadToken()
+		if err != nil {
+			t.Fatalf("token %d: %s", i, err)
+		}
+		if tok.Type != e {
+			t.Errorf("token %d: expected %s, got %s", i, e, tok.Type)
+		}
+	}
+	if _, err := tr.ReadToken(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestCborTokenReaderBignum(t *testing.T) {
+	pos := new(big.Int).Lsh(big.NewInt(1), 64)
+	pos.Add(pos, big.NewInt(1))
+	neg := new(big.Int).Neg(pos)
+	neg.Sub(neg, big.NewInt(1))
+	n := ipld.Node{"pos": pos, "neg": neg}
+
+	data, err := mc.Marshal(CanonicalCborMulticodec(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ipld.Node
+	if err := mc.Unmarshal(CanonicalCborMulticodec(), data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := NewTokenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := ReadValue(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, n) || !reflect.DeepEqual(v, decoded) {
+		t.Errorf("expected %#v, got %#v and decoded %#v", n, v, decoded)
+	}
+}
+
+func TestJsonTokenReaderReserved(t *testing.T) {
+	c, err := ipld.ParseCid(tokenHashA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewJsonTokenReader(strings.NewReader(`{"/a": {"/": "` + tokenHashA + `"}, "b": {"/": {"bytes": "aGk"}}, "c": {}}`))
+	expected := []Token{
+		{Type: TokenMapStart, Length: -1},
+		{Type: TokenKey, Value: "/a"},
+		{Type: TokenLink, Value: c},
+		{Type: TokenKey, Value: "b"},
+		{Type: TokenValue, Value: []byte("hi")},
+		{Type: TokenKey, Value: "c"},
+		{Type: TokenMapStart, Length: -1},
+		{Type: TokenMapEnd},
+		{Type: TokenMapEnd},
+	}
+	for i, e := range expected {
+		tok, err := tr.ReadToken()
+		if err != nil {
+			t.Fatalf("token %d: %s", i, err)
+		}
+		if !reflect.DeepEqual(tok, e) {
+			t.Errorf("token %d: expected %#v, got %#v", i, e, tok)
+		}
+	}
+
+	invalid := []string{
+		`{"a": 1, "/": "` + tokenHashA + `"}`,
+		`{"/": "` + tokenHashA + `", "a": 1}`,
+		`{"/": {"bytes": "aGk", "a": 1}}`,
+		`{"/": 1}`,
+	}
+	for _, in := range invalid {
+		if v, err := ReadValue(NewJsonTokenReader(strings.NewReader(in))); err == nil {
+			t.Errorf("%s: expected an error, got %#v", in, v)
+		}
+	}
+}
+
+func TestReadPath(t *testing.T) {
+	for _, codec := range []mc.Multicodec{CanonicalCborMulticodec(), JsonMulticodec()} {
+		data, err := mc.Marshal(codec, tokenNode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		paths := map[string]interface{}{
+			"title":          "big directory",
+			"entries/1/name": "b",
+			"entries/0/file": ipld.Node{"mlink": tokenHashA},
+			"entries/2/name": nil,
+			"missing":        nil,
+			"title/sub":      nil,
+		}
+		for p, expected := range paths {
+			tr, err := NewTokenReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := ReadPath(tr, ipld.ParsePath(p))
+			if err != nil {
+				t.Errorf("%s: %s", p, err)
+				continue
+			}
+			if !reflect.DeepEqual(v, expected) {
+				t.Errorf("%s: expected %#v, got %#v", p, expected, v)
+			}
+		}
+	}
+}
+
+func TestReadPathEscaped(t *testing.T) {
+	n := ipld.Node{
+		"@type":  "directive",
+		`\@type`: "user",
+		`a\\b`:   "backslash",
+		"a/b":    "slash",
+	}
+	data, err := mc.Marshal(CanonicalCborMulticodec(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"@type", `a\\b`, `a\/b`} {
+		tr, err := NewTokenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := ReadPath(tr, ipld.ParsePath(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := ipld.GetPath(n, p); v != expected || v == nil {
+			t.Errorf("%s: expected %#v, got %#v", p, expected, v)
+		}
+	}
+}
+
+func TestReadLinks(t *testing.T) {
+	n := ipld.Node{
+		"@attrs": ipld.Node{"owner": ipld.Node{"mlink": tokenHashB}},
+		"legacy": ipld.Node{"mlink": "not-a-cid"},
+		`\@user`: ipld.Node{"mlink": tokenHashB},
+	}
+	for k, v := range tokenNode {
+		n[k] = v
+	}
+	expected := ipld.Links(n)
+	if _, ok := expected["@user"]; !ok || len(expected) != 5 {
+		t.Fatalf("unexpected ipld.Links result %#v", expected)
+	}
+
+	for _, codec := range []mc.Multicodec{CanonicalCborMulticodec(), LinkTagCborMulticodec(), JsonMulticodec()} {
+		data, err := mc.Marshal(codec, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, err := NewTokenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		links, err := ReadLinks(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(links) != len(expected) {
+			t.Errorf("%s: expected %d links, got %#v", codec.Header(), len(expected), links)
+		}
+		for p, l := range expected {
+			if links[p].LinkStr() != l.LinkStr() {
+				t.Errorf("%s: %s: expected %s, got %#v", codec.Header(), p, l.LinkStr(), links[p])
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package ipld
+
+// hamtNode is a node of a persistent hash array mapped trie. Nodes are
+// never modified once built: updates copy the nodes on the path to the
+// entry and share all the others.
+//
+// Each level consumes hamtBits of the 64 bits hash of the keys. A slot is
+// present in slots if its bit is set in bitmap, and holds either an entry
+// or a child node. Keys whose hashes are equal end up in the collisions
+// of a node below the last level.
+type hamtNode struct {
+	bitmap     uint32
+	slots      []hamtSlot
+	collisions []hamtEntry
+}
+
+type hamtSlot struct {
+	entry hamtEntry
+	node  *hamtNode // nil if the slot holds entry
+}
+
+type hamtEntry struct {
+	key   string
+	hash  uint64 // hashKey(key), kept to move the entry down the trie
+	value interface{}
+}
+
+const (
+	hamtBits     = 5
+	hamtMask     = 1<<hamtBits - 1
+	hamtMaxShift = 64
+)
+
+// hashKey is the 64 bits FNV-1a hash of k.
+func hashKey(k string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(k); i++ {
+		h ^= uint64(k[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+func popcount(x uint32) int {
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
+
+// slot returns the bit of the slot of hash h at shift, and its index in
+// slots if it is present.
+func (n *hamtNode) slot(h uint64, shift uint) (bit uint32, i int) {
+	bit = 1 << ((h >> shift) & hamtMask)
+	return bit, popcount(n.bitmap & (bit - 1))
+}
+
+func (n *hamtNode) clone() *hamtNode {
+	return &hamtNode{
+		bitmap:     n.bitmap,
+		slots:      append([]hamtSlot(nil), n.slots...),
+		collisions: append([]hamtEntry(nil), n.collisions...),
+	}
+}
+
+func (n *hamtNode) get(key string, h uint64, shift uint) (interface{}, bool) {
+	for n != nil {
+		if shift >= hamtMaxShift {
+			for _, e := range n.collisions {
+				if e.key == key {
+					return e.value, true
+				}
+			}
+			return nil, false
+		}
+
+		bit, i := n.slot(h, shift)
+		if n.bitmap&bit == 0 {
+			return nil, false
+		}
+		s := n.slots[i]
+		if s.node == nil {
+			if s.entry.key == key {
+				return s.entry.value, true
+			}
+			return nil, false
+		}
+		n, shift = s.node, shift+hamtBits
+	}
+	return nil, false
+}
+
+// set returns a node where key is set to value, and whether key was added.
+func (n *hamtNode) set(key string, h uint64, shift uint, value interface{}) (*hamtNode, bool) {
+	c := n.clone()
+	if shift >= hamtMaxShift {
+		for i, e := range c.collisions {
+			if e.key == key {
+				c.collisions[i].value = value
+				return c, false
+			}
+		}
+		c.collisions = append(c.collisions, hamtEntry{key, h, value})
+		return c, true
+	}
+
+	bit, i := n.slot(h, shift)
+	if n.bitmap&bit == 0 {
+		c.bitmap |= bit
+		c.slots = append(c.slots, hamtSlot{})
+		copy(c.slots[i+1:], c.slots[i:])
+		c.slots[i] = hamtSlot{entry: hamtEntry{key, h, value}}
+		return c, true
+	}
+
+	s := n.slots[i]
+	switch {
+	case s.node != nil:
+		child, added := s.node.set(key, h, shift+hamtBits, value)
+		c.slots[i].node = child
+		return c, added
+	case s.entry.key == key:
+		c.slots[i].entry.value = value
+		return c, false
+	}
+
+	// two keys share the slot, move them to a new child node.
+	child, _ := (&hamtNode{}).set(s.entry.key, s.entry.hash, shift+hamtBits, s.entry.value)
+	child, _ = child.set(key, h, shift+hamtBits, value)
+	c.slots[i] = hamtSlot{node: child}
+	return c, true
+}
+
+// remove returns a node without key, and whether key was present. Nodes
+// left with a single entry are folded into their parent.
+func (n *hamtNode) remove(key string, h uint64, shift uint) (*hamtNode, bool) {
+	if shift >= hamtMaxShift {
+		for i, e := range n.collisions {
+			if e.key == key {
+				c := &hamtNode{collisions: make([]hamtEntry, 0, len(n.collisions)-1)}
+				c.collisions = append(c.collisions, n.collisions[:i]...)
+				c.collisions = append(c.collisions, n.collisions[i+1:]...)
+				return c, true
+			}
+		}
+		return n, false
+	}
+
+	bit, i := n.slot(h, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	s := n.slots[i]
+	if s.node == nil {
+		if s.entry.key != key {
+			return n, false
+		}
+		return n.without(bit, i), true
+	}
+
+	child, removed := s.node.remove(key, h, shift+hamtBits)
+	if !removed {
+		return n, false
+	}
+	if e, ok := child.single(); ok {
+		c := n.clone()
+		c.slots[i] = hamtSlot{entry: e}
+		return c, true
+	}
+	if len(child.slots) == 0 && len(child.collisions) == 0 {
+		return n.without(bit, i), true
+	}
+	c := n.clone()
+	c.slots[i].node = child
+	return c, true
+}
+
+// without returns a copy of n without the slot i of the given bit.
+func (n *hamtNode) without(bit uint32, i int) *hamtNode {
+	c := &hamtNode{
+		bitmap: n.bitmap &^ bit,
+		slots:  make([]hamtSlot, 0, len(n.slots)-1),
+	}
+	c.slots = append(c.slots, n.slots[:i]...)
+	c.slots = append(c.slots, n.slots[i+1:]...)
+	return c
+}
+
+// single returns the entry of n if it is the only one in the node, and n
+// has no children.
+func (n *hamtNode) single() (hamtEntry, bool) {
+	switch {
+	case len(n.slots) == 1 && n.slots[0].node == nil && len(n.collisions) == 0:
+		return n.slots[0].entry, true
+	case len(n.slots) == 0 && len(n.collisions) == 1:
+		return n.collisions[0], true
+	}
+	return hamtEntry{}, false
+}
+
+func (n *hamtNode) each(fn func(e hamtEntry)) {
+	if n == nil {
+		return
+	}
+	for _, s := range n.slots {
+		if s.node != nil {
+			s.node.each(fn)
+		} else {
+			fn(s.entry)
+		}
+	}
+	for _, e := range n.collisions {
+		fn(e)
+	}
+}
@@ -0,0 +1,76 @@
+package ipld
+
+import (
+	"errors"
+
+	blake2b "golang.org/x/crypto/blake2b"
+
+	mh "github.com/jbenet/go-multihash"
+)
+
+// DefaultHashFunc is the multihash function used by Node.Multihash and
+// LinkTo.
+var DefaultHashFunc = mh.SHA2_256
+
+// ErrNoMarshaler is returned when hashing a node while no MarshalFunc was
+// registered. Importing the coding package registers one.
+var ErrNoMarshaler = errors.New("no marshaler registered, import the coding package")
+
+// MarshalFunc serializes a node to the bytes that are hashed.
+type MarshalFunc func(n Node) ([]byte, error)
+
+var marshalFunc MarshalFunc
+
+// SetMarshaler sets the function used to serialize nodes before hashing
+// them. The coding package registers its muxing multicodec, which uses
+// the "@codec" of the node, or CBOR by default.
+func SetMarshaler(f MarshalFunc) {
+	marshalFunc = f
+}
+
+// Sum computes the multihash of data using the hash function identified
+// by the multihash code. Supported codes are mh.SHA1, mh.SHA2_256,
+// mh.SHA2_512, mh.SHA3 and mh.BLAKE2B.
+func Sum(data []byte, code int) (mh.Multihash, error) {
+	switch code {
+	case mh.BLAKE2B:
+		d := blake2b.Sum512(data)
+		h, err := mh.Encode(d[:], code)
+		return mh.Multihash(h), err
+	default:
+		return mh.Sum(data, code, -1)
+	}
+}
+
+// Hash serializes n and returns its multihash, computed with the hash
+// function identified by the multihash code.
+func Hash(n Node, code int) (mh.Multihash, error) {
+	if marshalFunc == nil {
+		return nil, ErrNoMarshaler
+	}
+
+	data, err := marshalFunc(n)
+	if err != nil {
+		return nil, err
+	}
+	return Sum(data, code)
+}
+
+// Multihash returns the multihash of the node, computed with
+// DefaultHashFunc.
+func (n Node) Multihash() (mh.Multihash, error) {
+	return Hash(n, DefaultHashFunc)
+}
+
+// LinkTo returns a merkle-link to n:
+//
+//   { "mlink": "<multihash of n>" }
+//
+// It returns the error of Node.Multihash if n cannot be hashed.
+func LinkTo(n Node) (Link, error) {
+	h, err := n.Multihash()
+	if err != nil {
+		return nil, err
+	}
+	return Link{LinkKey: h.B58String()}, nil
+}
@@ -0,0 +1,65 @@
+package ipld
+
+import (
+	"encoding/json"
+	"testing"
+
+	mh "github.com/jbenet/go-multihash"
+)
+
+func TestHash(t *testing.T) {
+	defer SetMarshaler(marshalFunc)
+
+	n := Node{"foo": "bar"}
+
+	SetMarshaler(nil)
+	if _, err := n.Multihash(); err != ErrNoMarshaler {
+		t.Errorf("expected ErrNoMarshaler, got %v", err)
+	}
+	if l, err := LinkTo(n); l != nil || err != ErrNoMarshaler {
+		t.Errorf("expected ErrNoMarshaler, got %#v, %v", l, err)
+	}
+
+	SetMarshaler(func(n Node) ([]byte, error) {
+		return json.Marshal(n)
+	})
+
+	for _, code := range []int{mh.SHA1, mh.SHA2_256, mh.SHA2_512, mh.SHA3, mh.BLAKE2B} {
+		h, err := Hash(n, code)
+		if err != nil {
+			t.Errorf("%s: %s", mh.Codes[code], err)
+			continue
+		}
+		dh, err := mh.Decode(h)
+		if err != nil {
+			t.Errorf("%s: invalid multihash: %s", mh.Codes[code], err)
+			continue
+		}
+		if dh.Code != code || dh.Length != mh.DefaultLengths[code] {
+			t.Errorf("%s: unexpected multihash %#v", mh.Codes[code], dh)
+		}
+	}
+
+	h, err := n.Multihash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := Sum([]byte(`{"foo":"bar"}`), mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.B58String() != h2.B58String() {
+		t.Errorf("hash mismatch: %s != %s", h.B58String(), h2.B58String())
+	}
+
+	l, err := LinkTo(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsLink(Node(l)) {
+		t.Errorf("LinkTo did not return a link: %#v", l)
+	}
+	if lh, err := l.Hash(); err != nil || lh.B58String() != h.B58String() {
+		t.Errorf("link hash mismatch: %v, %v", lh, err)
+	}
+}
@@ -34,6 +34,22 @@ func (n Node) Get(path_ string) interface{} {
 	return GetPath(n, path_)
 }
 
+// Len returns the number of entries of the node.
+func (n Node) Len() int {
+	return len(n)
+}
+
+// Keys returns the keys of the node, in no particular order.
+func (n Node) Keys() []string {
+	return mapKeys(n, nil)
+}
+
+// Lookup returns the value at key, and whether it is present.
+func (n Node) Lookup(key string) (interface{}, bool) {
+	v, ok := n[key]
+	return v, ok
+}
+
 // Type is a convenience method to retrieve "@type", if there is one.
 func (d Node) Type() string {
 	s, _ := d[TypeKey].(string)
@@ -98,19 +114,37 @@ func (l Link) Type() string {
 }
 
 // HashStr returns the string value of l["mlink"],
-// which is the value we use to store hashes.
+// which is the value we use to store hashes. Cid values
+// are returned in their string representation.
 func (l Link) LinkStr() string {
-	s, _ := l[LinkKey].(string)
-	return s
+	switch v := l[LinkKey].(type) {
+	case string:
+		return v
+	case Cid:
+		return v.String()
+	}
+	return ""
+}
+
+// Cid returns the identifier of the link target. Legacy
+// links holding a base58 multihash return a version 0 Cid.
+func (l Link) Cid() (Cid, error) {
+	switch v := l[LinkKey].(type) {
+	case string:
+		return ParseCid(v)
+	case Cid:
+		return v, nil
+	}
+	return Cid{}, errors.New("no hash in link")
 }
 
 // Hash returns the multihash value of the link.
 func (l Link) Hash() (mh.Multihash, error) {
-	s := l.LinkStr()
-	if s == "" {
-		return nil, errors.New("no hash in link")
+	c, err := l.Cid()
+	if err != nil {
+		return nil, err
 	}
-	return mh.FromB58String(s)
+	return c.Hash, nil
 }
 
 // Equal returns whether two Link objects are equal.
@@ -140,18 +174,17 @@ func (l Link) Equal(l2 Link) bool {
 //			"bar/baz": { @type: mlink, @value: Qmbbbb... },
 //		}
 //
-// WARNING: your nodes should not use `/` as key names. it will
-// confuse link parsers. thus, if we find any map keys with slash
-// in them, we simply ignore them.
+// The keys are in the string form of Path, so a slash within a map key
+// is escaped as "\/".
 func Links(n Node) map[string]Link {
 	m := map[string]Link{}
-	Walk(n, func(root, curr Node, path string, err error) error {
+	Walk(n, func(root, curr Node, path Path, err error) error {
 		if err != nil {
 			return err // if anything went wrong, bail.
 		}
 
 		if l, ok := LinkCast(curr); ok {
-			m[path] = l
+			m[path.String()] = l
 		}
 		return nil
 	})
@@ -162,14 +195,25 @@ func Links(n Node) map[string]Link {
 // follow:
 //
 //   { "mlink": "<multihash>" }
+//
+// where the multihash may also be a Cid, or its string representation.
+// The map may be a Node or a Link.
 func IsLink(v interface{}) bool {
-	vn, ok := v.(Node)
-	if !ok {
+	var vn Node
+	switch vt := v.(type) {
+	case Node:
+		vn = vt
+	case Link:
+		vn = Node(vt)
+	default:
 		return false
 	}
 
-	_, ok = vn[LinkKey].(string)
-	return ok;
+	switch vn[LinkKey].(type) {
+	case string, Cid:
+		return true
+	}
+	return false
 }
 
 // returns the link value of an object. for now we assume that all links
@@ -181,8 +225,16 @@ func LinkCast(v interface{}) (l Link, ok bool) {
 		return
 	}
 
+	var vn Node
+	switch vt := v.(type) {
+	case Node:
+		vn = vt
+	case Link:
+		vn = Node(vt)
+	}
+
 	l = make(Link)
-	for k, v := range v.(Node) {
+	for k, v := range vn {
 		l[k] = v
 	}
 	return l, true
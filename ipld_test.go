@@ -31,6 +31,9 @@ func init() {
 			"baz": Node{
 				"mlink":  "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo",
 			},
+			"qux": Link{
+				"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPb",
+			},
 			"test": Node {
 				// This is not a link because mlink is not a string but a Node
 				"mlink": Node{
@@ -40,6 +43,7 @@ func init() {
 		},
 		links: map[string]string{
 			"baz":        "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo",
+			"qux":        "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPb",
 			"test/mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo",
 		},
 		typ: "",
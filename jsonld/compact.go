@@ -0,0 +1,619 @@
+package jsonld
+
+import (
+	"sort"
+	"strings"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// Compact implements the JSON-LD compaction algorithm: input is expanded,
+// then shortened using the terms, compact IRIs and type and language
+// mappings of the given context. ctx may be a context, an IRI to load
+// with the Options Loader, a list of these, or a document with a
+// "@context" key.
+//
+// The result contains ctx under "@context" unless it is empty. If several
+// top-level nodes remain, they are stored under "@graph".
+func Compact(input interface{}, ctx interface{}, opts *Options) (ipld.Node, error) {
+	opts = opts.orDefault()
+
+	expanded, err := Expand(input, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := asMap(ctx); ok {
+		if c, ok := m["@context"]; ok {
+			ctx = c
+		}
+	}
+
+	p := &processor{opts: opts}
+	active, err := p.parseContext(newActiveContext(opts.Base), ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	compacted, err := p.compact(active, "", expanded, false)
+	if err != nil {
+		return nil, err
+	}
+
+	res, ok := compacted.(ipld.Node)
+	if !ok {
+		res = ipld.Node{}
+		if s := arrayify(compacted); len(s) > 0 {
+			res[active.compactIRI("@graph", nil, true, false)] = s
+		}
+	}
+
+	if ctx != nil {
+		if m, ok := asMap(ctx); !ok || len(m) > 0 {
+			if s, ok := asSlice(ctx); !ok || len(s) > 0 {
+				res["@context"] = ctx
+			}
+		}
+	}
+	return res, nil
+}
+
+func (p *processor) compact(ctx *activeContext, prop string, elem interface{}, insideReverse bool) (interface{}, error) {
+	if s, ok := elem.([]interface{}); ok {
+		res := []interface{}{}
+		for _, item := range s {
+			c, err := p.compact(ctx, prop, item, insideReverse)
+			if err != nil {
+				return nil, err
+			}
+			if c != nil {
+				res = append(res, c)
+			}
+		}
+		container := ctx.container(prop)
+		if !p.opts.KeepArrays && len(res) == 1 && !container.has("@list") && !container.has("@set") {
+			return res[0], nil
+		}
+		return res, nil
+	}
+
+	m, ok := elem.(ipld.Node)
+	if !ok {
+		return elem, nil // scalar
+	}
+
+	_, isValueObject := m["@value"]
+	if ctx.previous != nil && !isValueObject && !(isNodeReference(m) && len(m) == 1) {
+		ctx = ctx.previous
+	}
+	if def := ctx.terms[prop]; def != nil && def.hasContext {
+		var err error
+		if ctx, err = p.parseScopedContext(ctx, def, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if isValueObject || isNodeReference(m) {
+		return ctx.compactValue(prop, m), nil
+	}
+
+	if l, ok := m["@list"]; ok && ctx.container(prop).has("@list") {
+		return p.compact(ctx, prop, l, insideReverse)
+	}
+
+	// the contexts of the types apply to the node, but not to the nodes
+	// nested in it. The types themselves are compacted without them.
+	typeCtx := ctx
+	var types []interface{}
+	if t, ok := m["@type"]; ok {
+		var terms []string
+		for _, t := range arrayify(t) {
+			term := typeCtx.compactIRI(t.(string), nil, true, false)
+			types = append(types, term)
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+		for _, term := range terms {
+			if def := typeCtx.terms[term]; def != nil && def.hasContext {
+				var err error
+				if ctx, err = p.parseScopedContext(ctx, def, true); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	res := ipld.Node{}
+	for _, eprop := range sortedKeys(m) {
+		evalue := m[eprop]
+
+		switch eprop {
+		case "@id":
+			res[ctx.compactIRI("@id", nil, true, false)] = ctx.compactIRI(evalue.(string), nil, false, false)
+			continue
+
+		case "@type":
+			alias := ctx.compactIRI("@type", nil, true, false)
+			if len(types) == 1 && !p.opts.KeepArrays && !ctx.container("@type").has("@set") {
+				res[alias] = types[0]
+			} else {
+				res[alias] = types
+			}
+			continue
+
+		case "@reverse":
+			c, err := p.compact(ctx, "@reverse", evalue, true)
+			if err != nil {
+				return nil, err
+			}
+			cm, _ := c.(ipld.Node)
+			for _, k := range sortedKeys(cm) {
+				if def := ctx.terms[k]; def != nil && def.reverse {
+					asArray := def.container.has("@set") || p.opts.KeepArrays
+					addValue(res, k, cm[k], asArray)
+					delete(cm, k)
+				}
+			}
+			if len(cm) > 0 {
+				res[ctx.compactIRI("@reverse", nil, true, false)] = cm
+			}
+			continue
+
+		case "@index":
+			if ctx.container(prop).has("@index") {
+				continue
+			}
+			res[ctx.compactIRI("@index", nil, true, false)] = evalue
+			continue
+
+		case "@graph":
+			c, err := p.compact(ctx, "@graph", evalue, false)
+			if err != nil {
+				return nil, err
+			}
+			res[ctx.compactIRI("@graph", nil, true, false)] = arrayify(c)
+			continue
+
+		case "@included":
+			c, err := p.compact(ctx, "@included", evalue, false)
+			if err != nil {
+				return nil, err
+			}
+			addValue(res, ctx.compactIRI("@included", nil, true, false), c, p.opts.KeepArrays)
+			continue
+		}
+
+		values := arrayify(evalue)
+		if len(values) == 0 {
+			itemProp := ctx.compactIRI(eprop, nil, true, insideReverse)
+			target, err := ctx.nestTarget(res, itemProp)
+			if err != nil {
+				return nil, err
+			}
+			addValue(target, itemProp, []interface{}{}, true)
+			continue
+		}
+
+		for _, item := range values {
+			itemProp := ctx.compactIRI(eprop, item, true, insideReverse)
+			if err := p.compactItem(ctx, res, eprop, itemProp, item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// nestTarget returns the node of res in which the values of itemProp are
+// stored: res, or the node of its "@nest" term.
+func (c *activeContext) nestTarget(res ipld.Node, itemProp string) (ipld.Node, error) {
+	def := c.terms[itemProp]
+	if def == nil || def.nest == "" {
+		return res, nil
+	}
+	if nest, err := c.expandIRI(def.nest, false, true, nil); err != nil || nest != "@nest" {
+		return nil, newError("invalid @nest value", "%s", def.nest)
+	}
+	target, _ := res[def.nest].(ipld.Node)
+	if target == nil {
+		target = ipld.Node{}
+		res[def.nest] = target
+	}
+	return target, nil
+}
+
+// compactItem compacts item, a value of eprop, and adds it to res under
+// itemProp, the term selected for it.
+func (p *processor) compactItem(ctx *activeContext, res ipld.Node, eprop, itemProp string, item interface{}) error {
+	container := ctx.container(itemProp)
+	target, err := ctx.nestTarget(res, itemProp)
+	if err != nil {
+		return err
+	}
+
+	asArray := p.opts.KeepArrays || container.has("@set") || container.has("@list") ||
+		eprop == "@list" || eprop == "@graph"
+	mapObject := func() ipld.Node {
+		mapObj, _ := target[itemProp].(ipld.Node)
+		if mapObj == nil {
+			mapObj = ipld.Node{}
+			target[itemProp] = mapObj
+		}
+		return mapObj
+	}
+	none := ctx.compactIRI("@none", nil, true, false)
+
+	im, _ := item.(ipld.Node)
+	if isGraph(im) && container.has("@graph") {
+		c, err := p.compact(ctx, itemProp, im["@graph"], false)
+		if err != nil {
+			return err
+		}
+		switch {
+		case container.has("@id"):
+			key := none
+			if id, ok := im["@id"].(string); ok {
+				key = ctx.compactIRI(id, nil, false, false)
+			}
+			addValue(mapObject(), key, c, asArray)
+		case container.has("@index"):
+			key := none
+			if idx, ok := im["@index"].(string); ok {
+				key = idx
+			}
+			addValue(mapObject(), key, c, asArray)
+		default:
+			// several nodes would be read as several graphs
+			if cs, ok := c.([]interface{}); ok && len(cs) > 1 {
+				c = ipld.Node{ctx.compactIRI("@included", nil, true, false): cs}
+			}
+			addValue(target, itemProp, c, asArray)
+		}
+		return nil
+	}
+
+	list, isList := im["@list"]
+	var value interface{} = item
+	if isList {
+		value = list
+	}
+
+	c, err := p.compact(ctx, itemProp, value, false)
+	if err != nil {
+		return err
+	}
+
+	if isList {
+		c = arrayify(c)
+		if container.has("@list") {
+			if _, exists := target[itemProp]; exists {
+				return newError("compaction to list of lists", "%s", itemProp)
+			}
+			target[itemProp] = c
+			return nil
+		}
+		wrapped := ipld.Node{ctx.compactIRI("@list", nil, true, false): c}
+		if idx, ok := im["@index"]; ok {
+			wrapped[ctx.compactIRI("@index", nil, true, false)] = idx
+		}
+		c = wrapped
+	}
+
+	cm, _ := c.(ipld.Node)
+	var key interface{}
+	switch {
+	case container.has("@language"):
+		key = im["@language"]
+		if v, ok := cm["@value"]; ok {
+			c = v
+		}
+
+	case container.has("@index"):
+		def := ctx.terms[itemProp]
+		if def.index == "" {
+			key = im["@index"]
+			break
+		}
+		// the index is the first value of the index property
+		indexProp := ctx.compactIRI(def.index, nil, true, false)
+		if eindex, err := ctx.expandIRI(def.index, false, true, nil); err == nil {
+			indexProp = ctx.compactIRI(eindex, nil, true, false)
+		}
+		if values := arrayify(cm[indexProp]); cm != nil && cm[indexProp] != nil {
+			if s, ok := values[0].(string); ok {
+				key = s
+				if len(values) > 1 {
+					cm[indexProp] = values[1:]
+					if len(values) == 2 {
+						cm[indexProp] = values[1]
+					}
+				} else {
+					delete(cm, indexProp)
+				}
+			}
+		}
+
+	case container.has("@id"):
+		alias := ctx.compactIRI("@id", nil, true, false)
+		key = cm[alias]
+		delete(cm, alias)
+
+	case container.has("@type"):
+		alias := ctx.compactIRI("@type", nil, true, false)
+		if types := arrayify(cm[alias]); cm != nil && cm[alias] != nil {
+			key = types[0]
+			switch len(types) {
+			case 1:
+				delete(cm, alias)
+			case 2:
+				cm[alias] = types[1]
+			default:
+				cm[alias] = types[1:]
+			}
+		}
+		if _, ok := cm[ctx.compactIRI("@id", nil, true, false)]; ok && len(cm) == 1 {
+			if c, err = p.compact(ctx, itemProp, ipld.Node{"@id": im["@id"]}, false); err != nil {
+				return err
+			}
+		}
+
+	default:
+		addValue(target, itemProp, c, asArray)
+		return nil
+	}
+
+	k, ok := key.(string)
+	if !ok {
+		k = none
+	}
+	addValue(mapObject(), k, c, asArray)
+	return nil
+}
+
+// isNodeReference returns whether m only holds an "@id" (and possibly an
+// "@index").
+func isNodeReference(m ipld.Node) bool {
+	if _, ok := m["@id"]; !ok {
+		return false
+	}
+	_, hasIndex := m["@index"]
+	return len(m) == 1 || (len(m) == 2 && hasIndex)
+}
+
+// compactValue compacts a value object or a node reference, which may be
+// simplified to a scalar when the term definition of prop allows it.
+func (c *activeContext) compactValue(prop string, m ipld.Node) interface{} {
+	def := c.terms[prop]
+	if def == nil {
+		def = &termDef{}
+	}
+
+	n := len(m)
+	if _, ok := m["@index"]; ok && def.container.has("@index") {
+		n--
+	}
+
+	if id, ok := m["@id"].(string); ok && n == 1 {
+		switch def.typ {
+		case "@id":
+			return c.compactIRI(id, nil, false, false)
+		case "@vocab":
+			return c.compactIRI(id, nil, true, false)
+		}
+	}
+
+	if v, ok := m["@value"]; ok {
+		t, hasType := m["@type"]
+		l, hasLang := m["@language"]
+		d, hasDir := m["@direction"]
+		switch {
+		case hasType:
+			if n == 2 && t == def.typ {
+				return v
+			}
+		case !isString(v):
+			if n == 1 {
+				return v
+			}
+		case n == 1+btoi(hasLang)+btoi(hasDir):
+			lang, _ := l.(string)
+			dir, _ := d.(string)
+			if (lang == c.termLanguage(def) || def.container.has("@language")) && dir == c.termDirection(def) {
+				return v
+			}
+		}
+	}
+
+	res := ipld.Node{}
+	for _, k := range sortedKeys(m) {
+		v := m[k]
+		switch k {
+		case "@index":
+			if def.container.has("@index") {
+				continue
+			}
+		case "@type":
+			v = c.compactIRI(v.(string), nil, true, false)
+		case "@id":
+			v = c.compactIRI(v.(string), nil, false, false)
+		}
+		res[c.compactIRI(k, nil, true, false)] = v
+	}
+	return res
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// termLanguage returns the language applied to plain strings of a term.
+func (c *activeContext) termLanguage(def *termDef) string {
+	if def.hasLang {
+		return def.lang
+	}
+	return c.lang
+}
+
+// termDirection returns the direction applied to plain strings of a term.
+func (c *activeContext) termDirection(def *termDef) string {
+	if def.hasDir {
+		return def.dir
+	}
+	return c.dir
+}
+
+// compactIRI shortens an IRI to a term, a compact IRI or a relative IRI.
+// When vocab is true, terms are preferred, chosen according to how well
+// their definition matches value.
+func (c *activeContext) compactIRI(iri string, value interface{}, vocab, reverse bool) string {
+	if vocab {
+		if term, ok := c.selectTerm(iri, value, reverse); ok {
+			return term
+		}
+		if isKeyword(iri) {
+			return iri
+		}
+		if c.vocab != "" && strings.HasPrefix(iri, c.vocab) && len(iri) > len(c.vocab) {
+			suffix := iri[len(c.vocab):]
+			if _, ok := c.terms[suffix]; !ok {
+				return suffix
+			}
+		}
+	}
+
+	best := ""
+	for term, def := range c.terms {
+		if def == nil || !def.prefix || !strings.HasPrefix(iri, def.id) || len(iri) == len(def.id) {
+			continue
+		}
+		candidate := term + ":" + iri[len(def.id):]
+		if tdef, ok := c.terms[candidate]; ok && (tdef == nil || tdef.id != iri || !vocab) {
+			continue
+		}
+		if best == "" || len(candidate) < len(best) || (len(candidate) == len(best) && candidate < best) {
+			best = candidate
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	if !vocab && c.base != "" && strings.HasPrefix(iri, c.base) && len(iri) > len(c.base) && !strings.Contains(c.base[strings.LastIndex(c.base, "/")+1:], "#") {
+		if base := c.base[:strings.LastIndex(c.base, "/")+1]; strings.HasPrefix(iri, base) {
+			return iri[len(base):]
+		}
+	}
+	return iri
+}
+
+// selectTerm returns the best term mapped to iri for the given value.
+func (c *activeContext) selectTerm(iri string, value interface{}, reverse bool) (string, bool) {
+	best, bestScore := "", -1
+	for term, def := range c.terms {
+		if def == nil || def.id != iri || def.reverse != reverse || strings.Contains(term, ":") && isAbsoluteIRI(term) && term == iri {
+			continue
+		}
+		score, ok := c.termScore(def, value)
+		if !ok {
+			continue
+		}
+		if score > bestScore || (score == bestScore && (len(term) < len(best) || (len(term) == len(best) && term < best))) {
+			best, bestScore = term, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// termScore returns whether a term definition can be used to compact
+// value, and how specific it is.
+func (c *activeContext) termScore(def *termDef, value interface{}) (int, bool) {
+	container := def.container
+	score := 0
+	if len(container) > 0 {
+		score++
+	}
+	if def.typ != "" {
+		score++
+	}
+	if def.hasLang {
+		score++
+	}
+	if def.hasDir {
+		score++
+	}
+
+	m, ok := value.(ipld.Node)
+	if !ok {
+		// no value (property only), accept general terms
+		return score, !container.has("@list") && !container.has("@language")
+	}
+
+	if _, ok := m["@list"]; ok {
+		if container.has("@list") {
+			return score, true
+		}
+		return score, len(container) == 0 && def.typ == "" && !def.hasLang
+	}
+	if container.has("@list") {
+		return 0, false
+	}
+
+	if _, ok := m["@index"]; !ok && container.has("@index") && def.index == "" {
+		return 0, false
+	}
+
+	if v, ok := m["@value"]; ok {
+		if container.has("@id") || container.has("@type") || container.has("@graph") || def.index != "" {
+			return 0, false
+		}
+		if def.typ == "@none" {
+			return score, true
+		}
+		t, hasType := m["@type"]
+		_, hasLang := m["@language"]
+		_, hasDir := m["@direction"]
+		switch {
+		case hasType:
+			return score, def.typ == t && !container.has("@language")
+		case hasLang || hasDir:
+			lang, _ := m["@language"].(string)
+			dir, _ := m["@direction"].(string)
+			return score, def.typ == "" && (container.has("@language") || lang == c.termLanguage(def)) &&
+				dir == c.termDirection(def)
+		}
+		if container.has("@language") || def.typ != "" {
+			return 0, false
+		}
+		if isString(v) {
+			return score, c.termLanguage(def) == "" && c.termDirection(def) == ""
+		}
+		return score, true
+	}
+
+	// node object, graph object or reference
+	if container.has("@language") || def.hasLang || def.typ == "@json" {
+		return 0, false
+	}
+	if container.has("@graph") {
+		if !isGraph(m) {
+			return 0, false
+		}
+		_, hasID := m["@id"]
+		return score, container.has("@id") || !hasID
+	}
+	if container.has("@id") || container.has("@type") || def.typ == "@none" {
+		return score, true
+	}
+	if def.typ == "@id" || def.typ == "@vocab" {
+		return score, isNodeReference(m)
+	}
+	return score, def.typ == ""
+}
@@ -0,0 +1,151 @@
+package jsonld
+
+import (
+	"reflect"
+	"testing"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+func TestCompact(t *testing.T) {
+	doc := ipld.Node{
+		"@context": personContext,
+		"@id":      "http://example.org/alice",
+		"@type":    "Person",
+		"name":     "Alice",
+		"knows":    "http://example.org/bob",
+		"born":     "1970-01-01",
+		"nick":     []interface{}{"al"},
+		"label":    ipld.Node{"en": "Alice", "fr": "Alice"},
+		"steps":    []interface{}{"a", "b"},
+		"parent":   ipld.Node{"@id": "http://example.org/carol"},
+	}
+
+	res, err := Compact(doc, personContext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, doc) {
+		t.Errorf("compaction mismatch:\n%#v\n%#v", res, doc)
+	}
+}
+
+func TestCompactIRIs(t *testing.T) {
+	doc := ipld.Node{
+		"http://example.org/vocab#title": "Title",
+		"http://purl.org/dc/terms/date":  "2015",
+		"http://other.org/prop":          "other",
+	}
+	ctx := ipld.Node{
+		"ex":    "http://example.org/vocab#",
+		"dc":    "http://purl.org/dc/terms/",
+		"title": "ex:title",
+	}
+
+	res, err := Compact(doc, ipld.Node{"@context": ctx}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ipld.Node{
+		"@context":              ctx,
+		"title":                 "Title",
+		"dc:date":               "2015",
+		"http://other.org/prop": "other",
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("compaction mismatch:\n%#v\n%#v", res, expected)
+	}
+}
+
+func TestCompactGraph(t *testing.T) {
+	doc := []interface{}{
+		ipld.Node{"@id": "http://example.org/a", schemaOrg + "name": "A"},
+		ipld.Node{"@id": "http://example.org/b", schemaOrg + "name": "B"},
+	}
+
+	res, err := Compact(doc, nil, &Options{KeepArrays: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ipld.Node{
+		"@graph": []interface{}{
+			ipld.Node{"@id": "http://example.org/a", schemaOrg + "name": []interface{}{"A"}},
+			ipld.Node{"@id": "http://example.org/b", schemaOrg + "name": []interface{}{"B"}},
+		},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("compaction mismatch:\n%#v\n%#v", res, expected)
+	}
+}
+
+func TestCompactJsonLD11(t *testing.T) {
+	const ex = "http://example.org/"
+	docs := []ipld.Node{
+		{ // property-scoped context
+			"@context": ipld.Node{"@vocab": schemaOrg, "knows": ipld.Node{"@context": ipld.Node{"name": ex + "name"}}},
+			"knows":    ipld.Node{"name": "Bob"},
+		},
+		{ // type-scoped context, not propagated to nested nodes
+			"@context": ipld.Node{"@vocab": schemaOrg, "Person": ipld.Node{"@context": ipld.Node{"name": ex + "name"}}},
+			"@type":    "Person",
+			"name":     "Alice",
+			"knows":    ipld.Node{"name": "Bob"},
+		},
+		{
+			"@context": ipld.Node{
+				"@vocab":  schemaOrg,
+				"details": "@nest",
+				"name":    ipld.Node{"@id": schemaOrg + "name", "@nest": "details"},
+			},
+			"details": ipld.Node{"name": "Alice"},
+		},
+		{
+			"@context":  ipld.Node{"@vocab": schemaOrg},
+			"@id":       ex + "alice",
+			"@included": ipld.Node{"@id": ex + "bob", "name": "Bob"},
+		},
+		{
+			"@context": ipld.Node{"data": ipld.Node{"@id": schemaOrg + "data", "@type": "@json"}},
+			"data":     ipld.Node{"b": []interface{}{ipld.Int(1), true}},
+		},
+		{
+			"@context": ipld.Node{"@vocab": schemaOrg, "@language": "ar", "@direction": "rtl"},
+			"name":     "Alice",
+		},
+		{
+			"@context": ipld.Node{"@vocab": schemaOrg, "byId": ipld.Node{"@id": schemaOrg + "knows", "@container": "@id"}},
+			"byId":     ipld.Node{ex + "bob": ipld.Node{"name": "Bob"}},
+		},
+		{
+			"@context": ipld.Node{"@vocab": schemaOrg, "byType": ipld.Node{"@id": schemaOrg + "knows", "@container": "@type"}},
+			"byType":   ipld.Node{"Person": ipld.Node{"name": "Bob"}, "@none": ipld.Node{"name": "Carol"}},
+		},
+		{
+			"@context": ipld.Node{"@vocab": schemaOrg, "byName": ipld.Node{"@id": schemaOrg + "knows", "@container": "@index", "@index": "name"}},
+			"byName":   ipld.Node{"Bob": ipld.Node{"@id": ex + "bob"}},
+		},
+		{
+			"@context": ipld.Node{"@vocab": schemaOrg, "claim": ipld.Node{"@container": []interface{}{"@graph", "@set"}}},
+			"claim":    []interface{}{ipld.Node{"name": "Bob"}},
+		},
+		{
+			"@context": ipld.Node{"@vocab": schemaOrg, "graphs": ipld.Node{"@id": schemaOrg + "claim", "@container": []interface{}{"@graph", "@id"}}},
+			"graphs":   ipld.Node{ex + "g": ipld.Node{"name": "Bob"}},
+		},
+		{
+			"@context": ipld.Node{"@vocab": schemaOrg, "@type": ipld.Node{"@container": "@set"}},
+			"@type":    []interface{}{"Person"},
+		},
+	}
+
+	for i, doc := range docs {
+		res, err := Compact(doc, doc["@context"], nil)
+		if err != nil {
+			t.Errorf("#%d: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, doc) {
+			t.Errorf("#%d: compaction mismatch:\n%#v\n%#v", i, res, doc)
+		}
+	}
+}
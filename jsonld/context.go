@@ -0,0 +1,869 @@
+package jsonld
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// maxContextDepth limits the nesting of remote contexts.
+const maxContextDepth = 32
+
+var keywords = map[string]bool{
+	"@base": true, "@container": true, "@context": true, "@direction": true,
+	"@graph": true, "@id": true, "@import": true, "@included": true,
+	"@index": true, "@json": true, "@language": true, "@list": true,
+	"@nest": true, "@none": true, "@prefix": true, "@propagate": true,
+	"@protected": true, "@reverse": true, "@set": true, "@type": true,
+	"@value": true, "@version": true, "@vocab": true,
+}
+
+func isKeyword(s string) bool {
+	return keywords[s]
+}
+
+// looksLikeKeyword returns whether s has the form of a keyword, "@"
+// followed by letters. Such terms and IRIs are reserved, and ignored.
+func looksLikeKeyword(s string) bool {
+	if len(s) < 2 || s[0] != '@' {
+		return false
+	}
+	for _, r := range s[1:] {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// Error is a JSON-LD processing error. Code is one of the error codes
+// defined by the JSON-LD API specification, such as "invalid IRI mapping".
+type Error struct {
+	Code   string
+	Detail string
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return "jsonld: " + e.Code
+	}
+	return "jsonld: " + e.Code + ": " + e.Detail
+}
+
+func newError(code, format string, args ...interface{}) error {
+	return &Error{Code: code, Detail: fmt.Sprintf(format, args...)}
+}
+
+// containerMapping is the set of container keywords of a term, sorted.
+type containerMapping []string
+
+func (c containerMapping) has(k string) bool {
+	for _, v := range c {
+		if v == k {
+			return true
+		}
+	}
+	return false
+}
+
+// termDef is a term definition of an active context.
+type termDef struct {
+	id         string
+	reverse    bool
+	typ        string // "", "@id", "@vocab", "@json", "@none" or an IRI
+	hasLang    bool   // language mapping is set, lang == "" means null
+	lang       string
+	hasDir     bool // direction mapping is set, dir == "" means null
+	dir        string
+	container  containerMapping
+	index      string // property holding the keys of an @index container
+	nest       string // "@nest" or the term of the nested property
+	prefix     bool   // term may be used as a compact IRI prefix
+	protected  bool
+	hasContext bool        // context is set, it may be nil
+	context    interface{} // scoped context
+}
+
+// activeContext is the result of processing local contexts.
+type activeContext struct {
+	base  string
+	vocab string
+	lang  string
+	dir   string
+	terms map[string]*termDef // nil definitions are explicitly unmapped
+
+	// previous is the context to revert to in node objects, when the
+	// context was not propagated (see "@propagate").
+	previous *activeContext
+}
+
+func newActiveContext(base string) *activeContext {
+	return &activeContext{base: base, terms: map[string]*termDef{}}
+}
+
+func (c *activeContext) clone() *activeContext {
+	res := *c
+	res.terms = make(map[string]*termDef, len(c.terms))
+	for k, v := range c.terms {
+		res.terms[k] = v
+	}
+	return &res
+}
+
+// container returns the container mapping of a term.
+func (c *activeContext) container(term string) containerMapping {
+	if def := c.terms[term]; def != nil {
+		return def.container
+	}
+	return nil
+}
+
+// processor holds the state shared by the algorithms.
+type processor struct {
+	opts *Options
+}
+
+// contextFlags are the options of the processing of a local context.
+type contextFlags struct {
+	propagate bool // the context applies to nested node objects
+	override  bool // protected terms may be redefined, as by scoped contexts
+}
+
+// parseContext processes the local context into a new active context.
+func (p *processor) parseContext(active *activeContext, local interface{}, depth int) (*activeContext, error) {
+	return p.parseContextFlags(active, local, depth, contextFlags{propagate: true})
+}
+
+// parseScopedContext processes the scoped context of a term. Type-scoped
+// contexts are not propagated to nested node objects.
+func (p *processor) parseScopedContext(active *activeContext, def *termDef, typeScoped bool) (*activeContext, error) {
+	return p.parseContextFlags(active, def.context, 0, contextFlags{propagate: !typeScoped, override: true})
+}
+
+func (p *processor) parseContextFlags(active *activeContext, local interface{}, depth int, flags contextFlags) (*activeContext, error) {
+	if depth > maxContextDepth {
+		return nil, newError("recursive context inclusion", "too many nested contexts")
+	}
+
+	if m, ok := asMap(local); ok {
+		if v, ok := m["@propagate"]; ok {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, newError("invalid @propagate value", "%v", v)
+			}
+			flags.propagate = b
+		}
+	}
+
+	result := active.clone()
+	if !flags.propagate && result.previous == nil {
+		result.previous = active
+	}
+
+	for _, ctx := range arrayify(local) {
+		if ctx == nil {
+			if !flags.override {
+				for term, def := range result.terms {
+					if def != nil && def.protected {
+						return nil, newError("invalid context nullification", "%s is protected", term)
+					}
+				}
+			}
+			prev := result
+			result = newActiveContext(p.opts.Base)
+			if !flags.propagate {
+				result.previous = prev
+			}
+			continue
+		}
+
+		if iri, ok := ctx.(string); ok {
+			loaded, err := p.loadContext(result, iri)
+			if err != nil {
+				return nil, err
+			}
+			result, err = p.parseContextFlags(result, loaded, depth+1, contextFlags{propagate: true, override: flags.override})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		m, ok := asMap(ctx)
+		if !ok {
+			return nil, newError("invalid local context", "%T", ctx)
+		}
+
+		if v, ok := m["@version"]; ok {
+			if f, ok := ipld.Number(v); !ok || f != ipld.Float(1.1) {
+				return nil, newError("invalid @version value", "%v", v)
+			}
+		}
+
+		if v, ok := m["@import"]; ok {
+			imported, err := p.importContext(result, v)
+			if err != nil {
+				return nil, err
+			}
+			merged := make(ipld.Node, len(imported)+len(m))
+			for k, iv := range imported {
+				merged[k] = iv
+			}
+			for k, lv := range m {
+				merged[k] = lv
+			}
+			m = merged
+		}
+
+		if v, ok := m["@base"]; ok {
+			switch b := v.(type) {
+			case nil:
+				result.base = ""
+			case string:
+				result.base = resolveIRI(result.base, b)
+			default:
+				return nil, newError("invalid base IRI", "%v", v)
+			}
+		}
+
+		if v, ok := m["@vocab"]; ok {
+			switch vocab := v.(type) {
+			case nil:
+				result.vocab = ""
+			case string:
+				if vocab != "" || result.base != "" {
+					expanded, err := result.expandIRI(vocab, true, true, nil)
+					if err != nil {
+						return nil, err
+					}
+					vocab = expanded
+				}
+				if !isAbsoluteIRI(vocab) && !strings.HasPrefix(vocab, "_:") {
+					return nil, newError("invalid vocab mapping", "%s", vocab)
+				}
+				result.vocab = vocab
+			default:
+				return nil, newError("invalid vocab mapping", "%v", v)
+			}
+		}
+
+		if v, ok := m["@language"]; ok {
+			switch lang := v.(type) {
+			case nil:
+				result.lang = ""
+			case string:
+				result.lang = strings.ToLower(lang)
+			default:
+				return nil, newError("invalid default language", "%v", v)
+			}
+		}
+
+		if v, ok := m["@direction"]; ok {
+			dir, err := parseDirection(v)
+			if err != nil {
+				return nil, err
+			}
+			result.dir = dir
+		}
+
+		protected := false
+		if v, ok := m["@protected"]; ok {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, newError("invalid @protected value", "%v", v)
+			}
+			protected = b
+		}
+
+		tc := &termCreator{p: p, ctx: result, local: m, defined: map[string]bool{}, protected: protected, override: flags.override}
+		for _, term := range sortedKeys(m) {
+			switch term {
+			case "@base", "@direction", "@import", "@language", "@propagate", "@protected", "@version", "@vocab":
+				continue
+			}
+			if err := tc.create(term); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// importContext loads the context referenced by the "@import" entry of a
+// local context.
+func (p *processor) importContext(active *activeContext, v interface{}) (ipld.Node, error) {
+	iri, ok := v.(string)
+	if !ok {
+		return nil, newError("invalid @import value", "%v", v)
+	}
+	loaded, err := p.loadContext(active, iri)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := asMap(loaded)
+	if !ok {
+		return nil, newError("invalid remote context", "%s is not a map", iri)
+	}
+	if _, ok := m["@import"]; ok {
+		return nil, newError("invalid context entry", "@import in %s", iri)
+	}
+	return m, nil
+}
+
+// parseDirection checks the value of a "@direction" entry.
+func parseDirection(v interface{}) (string, error) {
+	switch v {
+	case nil:
+		return "", nil
+	case "ltr", "rtl":
+		return v.(string), nil
+	}
+	return "", newError("invalid base direction", "%v", v)
+}
+
+// loadContext retrieves a remote context and returns its "@context".
+func (p *processor) loadContext(active *activeContext, iri string) (interface{}, error) {
+	if p.opts.Loader == nil {
+		return nil, newError("loading remote context failed", "no loader for %s", iri)
+	}
+
+	doc, err := p.opts.Loader.LoadContext(resolveIRI(active.base, iri))
+	if err != nil {
+		return nil, newError("loading remote context failed", "%s: %s", iri, err)
+	}
+
+	m, ok := asMap(doc)
+	if !ok {
+		return nil, newError("invalid remote context", "%s", iri)
+	}
+	ctx, ok := m["@context"]
+	if !ok {
+		return nil, newError("invalid remote context", "%s has no @context", iri)
+	}
+	return ctx, nil
+}
+
+// termCreator creates the term definitions of a local context.
+type termCreator struct {
+	p         *processor
+	ctx       *activeContext // the active context being built
+	local     ipld.Node
+	defined   map[string]bool // false while the term is being defined
+	protected bool            // default protection of the terms
+	override  bool            // protected terms may be redefined
+}
+
+// termKeys are the entries allowed in expanded term definitions.
+var termKeys = map[string]bool{
+	"@id": true, "@reverse": true, "@type": true, "@container": true,
+	"@context": true, "@direction": true, "@index": true, "@language": true,
+	"@nest": true, "@prefix": true, "@protected": true,
+}
+
+// create creates the definition of term, found in the local context.
+func (tc *termCreator) create(term string) error {
+	if done, ok := tc.defined[term]; ok {
+		if !done {
+			return newError("cyclic IRI mapping", "%s", term)
+		}
+		return nil
+	}
+	if term == "" {
+		return newError("invalid term definition", "empty term")
+	}
+	tc.defined[term] = false
+	defer func() { tc.defined[term] = true }()
+
+	c := tc.ctx
+	value := tc.local[term]
+
+	if term == "@type" {
+		// only the container and the protection of @type may be set.
+		m, ok := asMap(value)
+		if !ok || len(m) == 0 {
+			return newError("keyword redefinition", "%s", term)
+		}
+		for k, v := range m {
+			switch {
+			case k == "@container" && v == "@set":
+			case k == "@protected":
+			default:
+				return newError("keyword redefinition", "%s", term)
+			}
+		}
+	} else if isKeyword(term) {
+		return newError("keyword redefinition", "%s", term)
+	} else if looksLikeKeyword(term) {
+		return nil
+	}
+
+	previous := c.terms[term]
+	delete(c.terms, term)
+
+	def, err := tc.definition(term, value)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil && previous.protected && !tc.override {
+		if !sameDefinition(previous, def) {
+			return newError("protected term redefinition", "%s", term)
+		}
+		def = previous
+	}
+	c.terms[term] = def
+	return nil
+}
+
+// sameDefinition returns whether two definitions are the same, apart from
+// their protection.
+func sameDefinition(a, b *termDef) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ac, bc := *a, *b
+	ac.protected, bc.protected = false, false
+	return reflect.DeepEqual(ac, bc)
+}
+
+// definition returns the definition of term given by value. It is nil if
+// the term is explicitly unmapped.
+func (tc *termCreator) definition(term string, value interface{}) (*termDef, error) {
+	c := tc.ctx
+	if value == nil {
+		return nil, nil
+	}
+
+	simple := false
+	if s, ok := value.(string); ok {
+		value = ipld.Node{"@id": s}
+		simple = true
+	}
+	m, ok := asMap(value)
+	if !ok {
+		return nil, newError("invalid term definition", "%s", term)
+	}
+	for _, k := range sortedKeys(m) {
+		if !termKeys[k] {
+			return nil, newError("invalid term definition", "%s: unexpected %s", term, k)
+		}
+	}
+
+	def := &termDef{protected: tc.protected}
+
+	if v, ok := m["@protected"]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, newError("invalid @protected value", "%s", term)
+		}
+		def.protected = b
+	}
+
+	if v, ok := m["@type"]; ok {
+		typ, ok := v.(string)
+		if !ok {
+			return nil, newError("invalid type mapping", "%s", term)
+		}
+		typ, err := c.expandIRI(typ, false, true, tc)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case typ == "@id", typ == "@vocab", typ == "@json", typ == "@none":
+		case isAbsoluteIRI(typ):
+		default:
+			return nil, newError("invalid type mapping", "%s: %s", term, typ)
+		}
+		def.typ = typ
+	}
+
+	if v, ok := m["@reverse"]; ok {
+		if _, ok := m["@id"]; ok {
+			return nil, newError("invalid reverse property", "%s", term)
+		}
+		if _, ok := m["@nest"]; ok {
+			return nil, newError("invalid reverse property", "%s", term)
+		}
+		rev, ok := v.(string)
+		if !ok {
+			return nil, newError("invalid IRI mapping", "%s", term)
+		}
+		if looksLikeKeyword(rev) && !isKeyword(rev) {
+			return nil, nil
+		}
+		id, err := c.expandIRI(rev, false, true, tc)
+		if err != nil {
+			return nil, err
+		}
+		if !isAbsoluteIRI(id) && !strings.HasPrefix(id, "_:") {
+			return nil, newError("invalid IRI mapping", "%s: %s", term, id)
+		}
+		def.id = id
+		def.reverse = true
+
+	} else if v, ok := m["@id"]; ok && v != term {
+		if v == nil {
+			return nil, nil
+		}
+		id, ok := v.(string)
+		if !ok {
+			return nil, newError("invalid IRI mapping", "%s", term)
+		}
+		if looksLikeKeyword(id) && !isKeyword(id) {
+			return nil, nil
+		}
+		id, err := c.expandIRI(id, false, true, tc)
+		if err != nil {
+			return nil, err
+		}
+		if !isKeyword(id) && !isAbsoluteIRI(id) && !strings.HasPrefix(id, "_:") {
+			return nil, newError("invalid IRI mapping", "%s: %s", term, id)
+		}
+		if id == "@context" {
+			return nil, newError("invalid keyword alias", "%s", term)
+		}
+
+		// terms which look like IRIs must expand to the same IRI.
+		if i := strings.Index(term, ":"); (i > 0 && i < len(term)-1) || strings.Contains(term, "/") {
+			tc.defined[term] = true
+			expanded, err := c.expandIRI(term, false, true, tc)
+			if err != nil {
+				return nil, err
+			}
+			if expanded != id {
+				return nil, newError("invalid IRI mapping", "%s does not expand to %s", term, id)
+			}
+		}
+		def.id = id
+
+		// simple terms mapped to IRIs ending with a gen-delim can be
+		// used as compact IRI prefixes.
+		if simple && !strings.ContainsAny(term, ":/") && id != "" {
+			def.prefix = strings.HasPrefix(id, "_:") || strings.ContainsAny(id[len(id)-1:], ":/?#[]@")
+		}
+
+	} else if i := strings.Index(term, ":"); i >= 0 {
+		prefix, suffix := term[:i], term[i+1:]
+		if _, ok := tc.local[prefix]; ok {
+			if err := tc.create(prefix); err != nil {
+				return nil, err
+			}
+		}
+		if pdef := c.terms[prefix]; pdef != nil {
+			def.id = pdef.id + suffix
+		} else {
+			def.id = term
+		}
+
+	} else if strings.Contains(term, "/") {
+		id, err := c.expandIRI(term, false, true, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !isAbsoluteIRI(id) {
+			return nil, newError("invalid IRI mapping", "%s has no IRI", term)
+		}
+		def.id = id
+
+	} else if term == "@type" {
+		def.id = "@type"
+
+	} else if c.vocab != "" {
+		def.id = c.vocab + term
+
+	} else {
+		return nil, newError("invalid IRI mapping", "%s has no IRI", term)
+	}
+
+	if v, ok := m["@container"]; ok {
+		container, err := parseContainer(term, v)
+		if err != nil {
+			return nil, err
+		}
+		if container.has("@type") {
+			switch def.typ {
+			case "":
+				def.typ = "@id"
+			case "@id", "@vocab":
+			default:
+				return nil, newError("invalid type mapping", "%s: %s with a @type container", term, def.typ)
+			}
+		}
+		if def.reverse {
+			for _, k := range container {
+				if k != "@set" && k != "@index" {
+					return nil, newError("invalid reverse property", "%s: %s container", term, k)
+				}
+			}
+		}
+		def.container = container
+	}
+
+	if v, ok := m["@index"]; ok {
+		index, ok := v.(string)
+		if !ok || !def.container.has("@index") {
+			return nil, newError("invalid term definition", "%s: invalid @index", term)
+		}
+		iri, err := c.expandIRI(index, false, true, tc)
+		if err != nil {
+			return nil, err
+		}
+		if isKeyword(iri) || !isAbsoluteIRI(iri) {
+			return nil, newError("invalid term definition", "%s: invalid @index %s", term, index)
+		}
+		def.index = index
+	}
+
+	if v, ok := m["@context"]; ok {
+		// the scoped context is checked now, and applied when the term
+		// is used.
+		if _, err := tc.p.parseContextFlags(c, v, 0, contextFlags{propagate: true, override: true}); err != nil {
+			return nil, newError("invalid scoped context", "%s: %s", term, err)
+		}
+		def.hasContext = true
+		def.context = v
+	}
+
+	if v, ok := m["@language"]; ok {
+		if _, hasType := m["@type"]; !hasType {
+			switch lang := v.(type) {
+			case nil:
+			case string:
+				def.lang = strings.ToLower(lang)
+			default:
+				return nil, newError("invalid language mapping", "%s", term)
+			}
+			def.hasLang = true
+		}
+	}
+
+	if v, ok := m["@direction"]; ok {
+		if _, hasType := m["@type"]; !hasType {
+			dir, err := parseDirection(v)
+			if err != nil {
+				return nil, err
+			}
+			def.dir = dir
+			def.hasDir = true
+		}
+	}
+
+	if v, ok := m["@nest"]; ok {
+		nest, ok := v.(string)
+		if !ok || (isKeyword(nest) && nest != "@nest") {
+			return nil, newError("invalid @nest value", "%s", term)
+		}
+		def.nest = nest
+	}
+
+	if v, ok := m["@prefix"]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, newError("invalid @prefix value", "%s", term)
+		}
+		if strings.ContainsAny(term, ":/") || (b && isKeyword(def.id)) {
+			return nil, newError("invalid term definition", "%s cannot be a prefix", term)
+		}
+		def.prefix = b
+	}
+
+	return def, nil
+}
+
+// parseContainer checks a container mapping: a keyword, or one of the
+// combinations of keywords allowed by JSON-LD 1.1.
+func parseContainer(term string, v interface{}) (containerMapping, error) {
+	var container containerMapping
+	for _, item := range arrayify(v) {
+		k, ok := item.(string)
+		if !ok {
+			return nil, newError("invalid container mapping", "%s: %v", term, v)
+		}
+		switch k {
+		case "@graph", "@id", "@index", "@language", "@list", "@set", "@type":
+		default:
+			return nil, newError("invalid container mapping", "%s: %s", term, k)
+		}
+		if container.has(k) {
+			return nil, newError("invalid container mapping", "%s: duplicate %s", term, k)
+		}
+		container = append(container, k)
+	}
+	sort.Strings(container)
+
+	others := containerMapping{}
+	for _, k := range container {
+		if k != "@set" {
+			others = append(others, k)
+		}
+	}
+	valid := false
+	switch {
+	case len(container) == 0:
+	case container.has("@list"):
+		valid = len(container) == 1
+	case others.has("@graph"):
+		// @graph, optionally with @id or @index, and @set
+		valid = len(others) == 1 || (len(others) == 2 && (others.has("@id") || others.has("@index")))
+	default:
+		valid = len(others) <= 1
+	}
+	if !valid {
+		return nil, newError("invalid container mapping", "%s: %v", term, v)
+	}
+	return container, nil
+}
+
+// expandIRI expands a value to an IRI. It returns "" if the value is
+// explicitly mapped to null. During context processing, tc is used to
+// create the terms the value depends on.
+func (c *activeContext) expandIRI(value string, docRelative, vocab bool, tc *termCreator) (string, error) {
+	if isKeyword(value) {
+		return value, nil
+	}
+	if looksLikeKeyword(value) {
+		return "", nil
+	}
+
+	if tc != nil {
+		if _, ok := tc.local[value]; ok && !tc.defined[value] {
+			if err := tc.create(value); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if vocab {
+		if def, ok := c.terms[value]; ok {
+			if def == nil {
+				return "", nil
+			}
+			return def.id, nil
+		}
+	}
+
+	if i := strings.Index(value, ":"); i >= 0 {
+		prefix, suffix := value[:i], value[i+1:]
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return value, nil
+		}
+		if tc != nil {
+			if _, ok := tc.local[prefix]; ok && !tc.defined[prefix] {
+				if err := tc.create(prefix); err != nil {
+					return "", err
+				}
+			}
+		}
+		if def := c.terms[prefix]; def != nil && def.prefix {
+			return def.id + suffix, nil
+		}
+		if isAbsoluteIRI(value) {
+			return value, nil
+		}
+	}
+
+	if vocab && c.vocab != "" {
+		return c.vocab + value, nil
+	}
+	if docRelative {
+		return resolveIRI(c.base, value), nil
+	}
+	return value, nil
+}
+
+// isAbsoluteIRI returns whether s has a scheme.
+func isAbsoluteIRI(s string) bool {
+	i := strings.Index(s, ":")
+	return i > 0 && !strings.ContainsAny(s[:i], "/?#")
+}
+
+// resolveIRI resolves a relative IRI against base.
+func resolveIRI(base, iri string) string {
+	if base == "" || isAbsoluteIRI(iri) {
+		return iri
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return iri
+	}
+	r, err := url.Parse(iri)
+	if err != nil {
+		return iri
+	}
+	return b.ResolveReference(r).String()
+}
+
+// asMap returns v as a node if it is a map with string keys.
+func asMap(v interface{}) (ipld.Node, bool) {
+	switch m := v.(type) {
+	case ipld.Node:
+		return m, true
+	case ipld.Link:
+		return ipld.Node(m), true
+	case map[string]interface{}:
+		return ipld.Node(m), true
+	}
+	return nil, false
+}
+
+// asSlice returns v as a []interface{} if it is any slice or array, except
+// byte slices.
+func asSlice(v interface{}) ([]interface{}, bool) {
+	if s, ok := v.([]interface{}); ok {
+		return s, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	s := make([]interface{}, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+	return s, true
+}
+
+// arrayify returns v as a slice, wrapping it if needed.
+func arrayify(v interface{}) []interface{} {
+	if s, ok := asSlice(v); ok {
+		return s
+	}
+	return []interface{}{v}
+}
+
+func sortedKeys(m ipld.Node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// addValue appends value to the values of key in m. If asArray is false
+// and key has no value yet, value is stored as-is.
+func addValue(m ipld.Node, key string, value interface{}, asArray bool) {
+	values := arrayify(value)
+	if value == nil {
+		values = nil
+	}
+
+	existing, ok := m[key]
+	if !ok {
+		if !asArray && len(values) == 1 {
+			m[key] = values[0]
+		} else {
+			m[key] = append([]interface{}{}, values...)
+		}
+		return
+	}
+	m[key] = append(append([]interface{}{}, arrayify(existing)...), values...)
+}
@@ -0,0 +1,705 @@
+package jsonld
+
+import (
+	"sort"
+	"strings"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// Options configures the JSON-LD algorithms.
+type Options struct {
+	// Base is the base IRI of the document.
+	Base string
+
+	// Loader retrieves the remote contexts referenced by IRI.
+	Loader ContextLoader
+
+	// ExpandContext is applied to the input before its own contexts, like
+	// a context in the document root. It is typically used to map the
+	// keys of IPLD nodes which do not have a "@context".
+	ExpandContext interface{}
+
+	// UseNativeTypes makes FromRDF convert xsd:boolean, xsd:integer and
+	// xsd:double literals to bool, ipld.Int or *big.Int, and ipld.Float.
+	UseNativeTypes bool
+
+	// KeepArrays disables the compaction of arrays of one element to that
+	// element.
+	KeepArrays bool
+}
+
+func (o *Options) orDefault() *Options {
+	if o == nil {
+		return &Options{}
+	}
+	return o
+}
+
+// Expand implements the JSON-LD expansion algorithm: all the contexts in
+// input are applied, terms and compact IRIs are expanded to absolute IRIs
+// and all values are put in their explicit form. The result is a list of
+// nodes.
+//
+// The algorithm is the one of JSON-LD 1.1, which also processes JSON-LD
+// 1.0 documents: scoped and protected contexts, "@nest", "@included",
+// "@json" literals, "@direction" and the "@graph", "@id" and "@type"
+// containers are supported.
+//
+// IPLD directives like "@attrs" or "@container": "@index" containers are
+// not JSON-LD, and should be converted first with ToLinkedDataAll.
+func Expand(input interface{}, opts *Options) ([]interface{}, error) {
+	p := &processor{opts: opts.orDefault()}
+	ctx := newActiveContext(p.opts.Base)
+	if p.opts.ExpandContext != nil {
+		local := p.opts.ExpandContext
+		if m, ok := asMap(local); ok {
+			if c, ok := m["@context"]; ok {
+				local = c
+			}
+		}
+		var err error
+		if ctx, err = p.parseContext(ctx, local, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := p.expand(ctx, "", input)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := res.(ipld.Node); ok && len(m) == 1 {
+		if g, ok := m["@graph"]; ok {
+			res = g
+		}
+	}
+	if res == nil {
+		return []interface{}{}, nil
+	}
+	return arrayify(res), nil
+}
+
+// isTopLevel returns whether the active property denotes a free-floating
+// node.
+func isTopLevel(prop string) bool {
+	return prop == "" || prop == "@graph"
+}
+
+func (p *processor) expand(ctx *activeContext, prop string, elem interface{}) (interface{}, error) {
+	return p.expandElem(ctx, prop, elem, false)
+}
+
+// expandElem expands elem, the value of prop. fromMap is set for the values
+// of @id, @index and @type maps, which keep the context of their property.
+func (p *processor) expandElem(ctx *activeContext, prop string, elem interface{}, fromMap bool) (interface{}, error) {
+	if elem == nil {
+		return nil, nil
+	}
+
+	var scoped *termDef
+	if def := ctx.terms[prop]; def != nil && def.hasContext {
+		scoped = def
+	}
+
+	if s, ok := asSlice(elem); ok {
+		res := []interface{}{}
+		for _, item := range s {
+			e, err := p.expandElem(ctx, prop, item, fromMap)
+			if err != nil {
+				return nil, err
+			}
+			if _, isArr := e.([]interface{}); isArr && ctx.container(prop).has("@list") {
+				e = ipld.Node{"@list": e}
+			}
+			if es, ok := e.([]interface{}); ok {
+				res = append(res, es...)
+			} else if e != nil {
+				res = append(res, e)
+			}
+		}
+		return res, nil
+	}
+
+	m, ok := asMap(elem)
+	if !ok { // scalar
+		if isTopLevel(prop) {
+			return nil, nil
+		}
+		if scoped != nil {
+			var err error
+			if ctx, err = p.parseScopedContext(ctx, scoped, false); err != nil {
+				return nil, err
+			}
+		}
+		return p.expandValue(ctx, prop, elem)
+	}
+
+	// contexts which are not propagated do not apply to node objects.
+	if ctx.previous != nil && !fromMap && !p.keepsContext(ctx, m) {
+		ctx = ctx.previous
+	}
+
+	var err error
+	if scoped != nil {
+		if ctx, err = p.parseScopedContext(ctx, scoped, false); err != nil {
+			return nil, err
+		}
+	}
+	if local, ok := m["@context"]; ok {
+		if ctx, err = p.parseContext(ctx, local, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	// the contexts of the types apply to the node, but not to the nodes
+	// nested in it. The types themselves are expanded without them.
+	typeCtx := ctx
+	for _, key := range sortedKeys(m) {
+		if eprop, _ := ctx.expandIRI(key, false, true, nil); eprop != "@type" {
+			continue
+		}
+		types := []string{}
+		for _, t := range arrayify(m[key]) {
+			if ts, ok := t.(string); ok {
+				types = append(types, ts)
+			}
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			if def := typeCtx.terms[t]; def != nil && def.hasContext {
+				if ctx, err = p.parseScopedContext(ctx, def, true); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	res := ipld.Node{}
+	if err := p.expandKeys(ctx, typeCtx, prop, m, res); err != nil {
+		return nil, err
+	}
+	return p.expandResult(prop, res)
+}
+
+// keepsContext returns whether the node m keeps a context which is not
+// propagated: values and node references are not node objects.
+func (p *processor) keepsContext(ctx *activeContext, m ipld.Node) bool {
+	for k := range m {
+		eprop, _ := ctx.expandIRI(k, false, true, nil)
+		if eprop == "@value" || (eprop == "@id" && len(m) == 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandKeys expands the entries of the node m into res. The keys nested
+// with "@nest" are expanded into res too.
+func (p *processor) expandKeys(ctx, typeCtx *activeContext, prop string, m, res ipld.Node) error {
+	var nests []string
+	for _, key := range sortedKeys(m) {
+		value := m[key]
+		if key == "@context" {
+			continue
+		}
+
+		eprop, err := ctx.expandIRI(key, false, true, nil)
+		if err != nil {
+			return err
+		}
+		if eprop == "" || (!isKeyword(eprop) && !strings.Contains(eprop, ":")) {
+			continue // not mapped to an IRI, drop it.
+		}
+
+		if isKeyword(eprop) {
+			if prop == "@reverse" {
+				return newError("invalid reverse property map", "%s", key)
+			}
+			if _, ok := res[eprop]; ok && eprop != "@included" && eprop != "@type" {
+				return newError("colliding keywords", "%s", eprop)
+			}
+			if eprop == "@nest" {
+				nests = append(nests, key)
+				continue
+			}
+			kctx := ctx
+			if eprop == "@type" {
+				kctx = typeCtx
+			}
+			if err := p.expandKeyword(kctx, prop, res, eprop, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		def := ctx.terms[key]
+		container := ctx.container(key)
+		var evalue interface{}
+
+		if def != nil && def.typ == "@json" {
+			evalue = ipld.Node{"@value": value, "@type": "@json"}
+
+		} else if vm, ok := asMap(value); ok && container.has("@language") {
+			dir := ctx.dir
+			if def != nil && def.hasDir {
+				dir = def.dir
+			}
+			values := []interface{}{}
+			for _, lang := range sortedKeys(vm) {
+				elang, err := ctx.expandIRI(lang, false, true, nil)
+				if err != nil {
+					return err
+				}
+				for _, item := range arrayify(vm[lang]) {
+					if item == nil {
+						continue
+					}
+					s, ok := item.(string)
+					if !ok {
+						return newError("invalid language map value", "%v", item)
+					}
+					v := ipld.Node{"@value": s}
+					if lang != "@none" && elang != "@none" {
+						v["@language"] = strings.ToLower(lang)
+					}
+					if dir != "" {
+						v["@direction"] = dir
+					}
+					values = append(values, v)
+				}
+			}
+			evalue = values
+
+		} else if vm, ok := asMap(value); ok && (container.has("@index") || container.has("@type") || container.has("@id")) {
+			evalue, err = p.expandMap(ctx, key, def, vm)
+			if err != nil {
+				return err
+			}
+
+		} else {
+			evalue, err = p.expand(ctx, key, value)
+			if err != nil {
+				return err
+			}
+		}
+
+		if evalue == nil {
+			continue
+		}
+		if container.has("@list") && !isList(evalue) {
+			evalue = ipld.Node{"@list": arrayify(evalue)}
+		}
+		if container.has("@graph") && !container.has("@id") && !container.has("@index") {
+			graphs := []interface{}{}
+			for _, item := range arrayify(evalue) {
+				graphs = append(graphs, ipld.Node{"@graph": arrayify(item)})
+			}
+			evalue = graphs
+		}
+
+		if def != nil && def.reverse {
+			rev, _ := res["@reverse"].(ipld.Node)
+			if rev == nil {
+				rev = ipld.Node{}
+				res["@reverse"] = rev
+			}
+			for _, item := range arrayify(evalue) {
+				if isList(item) || isValue(item) {
+					return newError("invalid reverse property value", "%s", key)
+				}
+				addValue(rev, eprop, item, true)
+			}
+			continue
+		}
+
+		addValue(res, eprop, evalue, true)
+	}
+
+	for _, key := range nests {
+		for _, nested := range arrayify(m[key]) {
+			nm, ok := asMap(nested)
+			if !ok {
+				return newError("invalid @nest value", "%v", nested)
+			}
+			for k := range nm {
+				if eprop, _ := ctx.expandIRI(k, false, true, nil); eprop == "@value" {
+					return newError("invalid @nest value", "%s has a value", key)
+				}
+			}
+			if err := p.expandKeys(ctx, typeCtx, prop, nm, res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandMap expands the value vm of a property with an @index, @id or
+// @type container: the keys of vm become the index, @id or @type of the
+// items, unless they expand to "@none".
+func (p *processor) expandMap(ctx *activeContext, key string, def *termDef, vm ipld.Node) (interface{}, error) {
+	container := def.container
+	mapCtx := ctx
+	if ctx.previous != nil && (container.has("@id") || container.has("@type")) {
+		mapCtx = ctx.previous
+	}
+	indexKey := "@index"
+	if def.index != "" {
+		indexKey = def.index
+	}
+
+	values := []interface{}{}
+	for _, index := range sortedKeys(vm) {
+		ictx := mapCtx
+		if container.has("@type") {
+			if idef := ctx.terms[index]; idef != nil && idef.hasContext {
+				var err error
+				if ictx, err = p.parseScopedContext(mapCtx, idef, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		eindex, err := ctx.expandIRI(index, false, true, nil)
+		if err != nil {
+			return nil, err
+		}
+		e, err := p.expandElem(ictx, key, arrayify(vm[index]), true)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range arrayify(e) {
+			if container.has("@graph") && !isGraph(item) {
+				item = ipld.Node{"@graph": arrayify(item)}
+			}
+			im, _ := item.(ipld.Node)
+			switch {
+			case im == nil || eindex == "@none":
+			case container.has("@index") && indexKey != "@index":
+				if isValue(im) {
+					return nil, newError("invalid value object", "value indexed by %s", indexKey)
+				}
+				iv, err := p.expandValue(ctx, indexKey, index)
+				if err != nil {
+					return nil, err
+				}
+				eprop, err := ctx.expandIRI(indexKey, false, true, nil)
+				if err != nil {
+					return nil, err
+				}
+				if existing, ok := im[eprop]; ok {
+					im[eprop] = append([]interface{}{iv}, arrayify(existing)...)
+				} else {
+					im[eprop] = []interface{}{iv}
+				}
+			case container.has("@index"):
+				if _, ok := im["@index"]; !ok {
+					im["@index"] = index
+				}
+			case container.has("@id"):
+				if _, ok := im["@id"]; !ok {
+					id, err := ctx.expandIRI(index, true, false, nil)
+					if err != nil {
+						return nil, err
+					}
+					im["@id"] = id
+				}
+			case container.has("@type"):
+				types := []interface{}{eindex}
+				if existing, ok := im["@type"]; ok {
+					types = append(types, arrayify(existing)...)
+				}
+				im["@type"] = types
+			}
+			values = append(values, item)
+		}
+	}
+	return values, nil
+}
+
+// expandKeyword expands the value of a keyword key in a node.
+func (p *processor) expandKeyword(ctx *activeContext, prop string, res ipld.Node, key string, value interface{}) error {
+	switch key {
+	case "@id":
+		id, ok := value.(string)
+		if !ok {
+			return newError("invalid @id value", "%v", value)
+		}
+		id, err := ctx.expandIRI(id, true, false, nil)
+		if err != nil {
+			return err
+		}
+		res[key] = id
+
+	case "@type":
+		types := []interface{}{}
+		for _, t := range arrayify(value) {
+			ts, ok := t.(string)
+			if !ok {
+				return newError("invalid type value", "%v", value)
+			}
+			et, err := ctx.expandIRI(ts, true, true, nil)
+			if err != nil {
+				return err
+			}
+			types = append(types, et)
+		}
+		if existing, ok := res[key]; ok {
+			res[key] = append(arrayify(existing), types...)
+		} else if _, isArr := asSlice(value); isArr {
+			res[key] = types
+		} else {
+			res[key] = types[0]
+		}
+
+	case "@graph":
+		e, err := p.expand(ctx, "@graph", value)
+		if err != nil {
+			return err
+		}
+		res[key] = arrayify(e)
+
+	case "@included":
+		e, err := p.expand(ctx, "", value)
+		if err != nil {
+			return err
+		}
+		included := arrayify(e)
+		if e == nil {
+			included = nil
+		}
+		for _, item := range included {
+			if _, ok := item.(ipld.Node); !ok || isValue(item) || isList(item) {
+				return newError("invalid @included value", "%v", item)
+			}
+		}
+		if existing, ok := res[key]; ok {
+			included = append(arrayify(existing), included...)
+		}
+		res[key] = included
+
+	case "@value":
+		// maps and arrays are only allowed in @json literals, which is
+		// checked by expandResult.
+		res[key] = value
+
+	case "@language":
+		lang, ok := value.(string)
+		if !ok {
+			return newError("invalid language-tagged string", "%v", value)
+		}
+		res[key] = strings.ToLower(lang)
+
+	case "@direction":
+		if value != "ltr" && value != "rtl" {
+			return newError("invalid base direction", "%v", value)
+		}
+		res[key] = value
+
+	case "@index":
+		if _, ok := value.(string); !ok {
+			return newError("invalid @index value", "%v", value)
+		}
+		res[key] = value
+
+	case "@list":
+		if isTopLevel(prop) {
+			return nil
+		}
+		e, err := p.expand(ctx, prop, value)
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			e = []interface{}{}
+		}
+		res[key] = arrayify(e)
+
+	case "@set":
+		e, err := p.expand(ctx, prop, value)
+		if err != nil {
+			return err
+		}
+		res[key] = e
+
+	case "@reverse":
+		vm, ok := asMap(value)
+		if !ok {
+			return newError("invalid @reverse value", "%v", value)
+		}
+		e, err := p.expand(ctx, "@reverse", vm)
+		if err != nil {
+			return err
+		}
+		em, _ := e.(ipld.Node)
+		for _, k := range sortedKeys(em) {
+			if k == "@reverse" {
+				// reverse of reverse properties are forward properties
+				rm, _ := em[k].(ipld.Node)
+				for _, rk := range sortedKeys(rm) {
+					addValue(res, rk, rm[rk], true)
+				}
+				continue
+			}
+			rev, _ := res["@reverse"].(ipld.Node)
+			if rev == nil {
+				rev = ipld.Node{}
+				res["@reverse"] = rev
+			}
+			for _, item := range arrayify(em[k]) {
+				if isList(item) || isValue(item) {
+					return newError("invalid reverse property value", "%s", k)
+				}
+				addValue(rev, k, item, true)
+			}
+		}
+	}
+	return nil
+}
+
+// expandResult applies the final checks of the expansion of a node.
+func (p *processor) expandResult(prop string, res ipld.Node) (interface{}, error) {
+	if v, ok := res["@value"]; ok {
+		for k := range res {
+			switch k {
+			case "@value", "@language", "@direction", "@type", "@index":
+			default:
+				return nil, newError("invalid value object", "unexpected %s", k)
+			}
+		}
+		_, hasLang := res["@language"]
+		_, hasDir := res["@direction"]
+		if hasLang || hasDir {
+			if _, ok := res["@type"]; ok {
+				return nil, newError("invalid value object", "both @type and @language")
+			}
+		}
+		if res["@type"] == "@json" {
+			return res, nil
+		}
+		if _, isArr := asSlice(v); isArr {
+			return nil, newError("invalid value object value", "%v", v)
+		}
+		if _, isMap := asMap(v); isMap {
+			return nil, newError("invalid value object value", "%v", v)
+		}
+		if v == nil {
+			return nil, nil
+		}
+		if hasLang || hasDir {
+			if _, ok := v.(string); !ok {
+				return nil, newError("invalid language-tagged value", "%v", v)
+			}
+		}
+		if t, ok := res["@type"]; ok {
+			if ts, ok := t.(string); !ok || (isKeyword(ts) && ts != "@json") {
+				return nil, newError("invalid typed value", "%v", t)
+			}
+		}
+		return res, nil
+	}
+
+	if t, ok := res["@type"]; ok {
+		res["@type"] = arrayify(t)
+	}
+
+	if _, ok := res["@list"]; ok {
+		if len(res) > 2 || (len(res) == 2 && res["@index"] == nil) {
+			return nil, newError("invalid set or list object", "unexpected keys")
+		}
+	}
+	if set, ok := res["@set"]; ok {
+		if len(res) > 2 || (len(res) == 2 && res["@index"] == nil) {
+			return nil, newError("invalid set or list object", "unexpected keys")
+		}
+		return set, nil
+	}
+
+	if _, ok := res["@language"]; ok && len(res) == 1 {
+		return nil, nil
+	}
+
+	if isTopLevel(prop) {
+		if len(res) == 0 {
+			return nil, nil
+		}
+		if _, ok := res["@list"]; ok {
+			return nil, nil
+		}
+		if _, ok := res["@id"]; ok && len(res) == 1 {
+			return nil, nil
+		}
+	}
+	return res, nil
+}
+
+// expandValue expands a scalar value of property prop.
+func (p *processor) expandValue(ctx *activeContext, prop string, value interface{}) (interface{}, error) {
+	def := ctx.terms[prop]
+
+	if s, ok := value.(string); ok && def != nil {
+		switch def.typ {
+		case "@id":
+			id, err := ctx.expandIRI(s, true, false, nil)
+			return ipld.Node{"@id": id}, err
+		case "@vocab":
+			id, err := ctx.expandIRI(s, true, true, nil)
+			return ipld.Node{"@id": id}, err
+		}
+	}
+
+	res := ipld.Node{"@value": value}
+	if def != nil && def.typ != "" && def.typ != "@id" && def.typ != "@vocab" && def.typ != "@none" {
+		res["@type"] = def.typ
+	} else if _, ok := value.(string); ok {
+		lang, dir := ctx.lang, ctx.dir
+		if def != nil && def.hasLang {
+			lang = def.lang
+		}
+		if def != nil && def.hasDir {
+			dir = def.dir
+		}
+		if lang != "" {
+			res["@language"] = lang
+		}
+		if dir != "" {
+			res["@direction"] = dir
+		}
+	}
+	return res, nil
+}
+
+func isList(v interface{}) bool {
+	m, ok := v.(ipld.Node)
+	if !ok {
+		return false
+	}
+	_, ok = m["@list"]
+	return ok
+}
+
+func isValue(v interface{}) bool {
+	m, ok := v.(ipld.Node)
+	if !ok {
+		return false
+	}
+	_, ok = m["@value"]
+	return ok
+}
+
+// isGraph returns whether v is a graph object.
+func isGraph(v interface{}) bool {
+	m, ok := v.(ipld.Node)
+	if !ok {
+		return false
+	}
+	_, ok = m["@graph"]
+	for k := range m {
+		if k != "@graph" && k != "@id" && k != "@index" {
+			return false
+		}
+	}
+	return ok
+}
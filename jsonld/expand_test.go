@@ -0,0 +1,373 @@
+package jsonld
+
+import (
+	"reflect"
+	"testing"
+
+	ipld "github.com/ipfs/go-ipld"
+	store "github.com/ipfs/go-ipld/store"
+	traverse "github.com/ipfs/go-ipld/traverse"
+)
+
+const schemaOrg = "http://schema.org/"
+
+var personContext = ipld.Node{
+	"@vocab": schemaOrg,
+	"xsd":    "http://www.w3.org/2001/XMLSchema#",
+	"knows":  ipld.Node{"@type": "@id"},
+	"born":   ipld.Node{"@id": "birthDate", "@type": "xsd:date"},
+	"nick":   ipld.Node{"@id": "alternateName", "@container": "@set"},
+	"label":  ipld.Node{"@id": "name", "@container": "@language"},
+	"steps":  ipld.Node{"@id": "step", "@container": "@list"},
+	"parent": ipld.Node{"@reverse": "children"},
+}
+
+func TestExpand(t *testing.T) {
+	doc := ipld.Node{
+		"@context": personContext,
+		"@id":      "http://example.org/alice",
+		"@type":    "Person",
+		"name":     "Alice",
+		"knows":    "http://example.org/bob",
+		"born":     "1970-01-01",
+		"nick":     "al",
+		"label":    ipld.Node{"en": "Alice", "fr": "Alice"},
+		"steps":    []interface{}{"a", "b"},
+		"parent":   ipld.Node{"@id": "http://example.org/carol"},
+	}
+
+	expected := []interface{}{
+		ipld.Node{
+			"@id":   "http://example.org/alice",
+			"@type": []interface{}{schemaOrg + "Person"},
+			schemaOrg + "name": []interface{}{
+				ipld.Node{"@value": "Alice", "@language": "en"},
+				ipld.Node{"@value": "Alice", "@language": "fr"},
+				ipld.Node{"@value": "Alice"},
+			},
+			schemaOrg + "knows": []interface{}{
+				ipld.Node{"@id": "http://example.org/bob"},
+			},
+			schemaOrg + "birthDate": []interface{}{
+				ipld.Node{"@value": "1970-01-01", "@type": "http://www.w3.org/2001/XMLSchema#date"},
+			},
+			schemaOrg + "alternateName": []interface{}{
+				ipld.Node{"@value": "al"},
+			},
+			schemaOrg + "step": []interface{}{
+				ipld.Node{"@list": []interface{}{
+					ipld.Node{"@value": "a"},
+					ipld.Node{"@value": "b"},
+				}},
+			},
+			"@reverse": ipld.Node{
+				schemaOrg + "children": []interface{}{
+					ipld.Node{"@id": "http://example.org/carol"},
+				},
+			},
+		},
+	}
+
+	res, err := Expand(doc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expansion mismatch:\n%#v\n%#v", res, expected)
+	}
+}
+
+func TestExpandDropsUnmapped(t *testing.T) {
+	doc := ipld.Node{
+		"@context": ipld.Node{"name": schemaOrg + "name"},
+		"name":     "Alice",
+		"unmapped": "dropped",
+	}
+
+	res, err := Expand(doc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		ipld.Node{schemaOrg + "name": []interface{}{ipld.Node{"@value": "Alice"}}},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expansion mismatch:\n%#v\n%#v", res, expected)
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	tests := map[string]ipld.Node{
+		"cyclic IRI mapping": {
+			"@context": ipld.Node{"a": "b:x", "b": "a:x"},
+			"a":        "value",
+		},
+		"invalid @id value": {
+			"@id": 42,
+		},
+		"keyword redefinition": {
+			"@context": ipld.Node{"@id": "http://example.org/id"},
+		},
+		"loading remote context failed": {
+			"@context": "http://example.org/missing",
+		},
+		"invalid @version value": {
+			"@context": ipld.Node{"@version": "1.1"},
+		},
+		"protected term redefinition": {
+			"@context": []interface{}{
+				ipld.Node{"@protected": true, "name": schemaOrg + "name"},
+				ipld.Node{"name": "http://example.org/name"},
+			},
+		},
+		"invalid context nullification": {
+			"@context": []interface{}{
+				ipld.Node{"@protected": true, "name": schemaOrg + "name"},
+				nil,
+			},
+		},
+		"invalid container mapping": {
+			"@context": ipld.Node{"name": ipld.Node{"@id": schemaOrg + "name", "@container": []interface{}{"@list", "@set"}}},
+		},
+		"invalid @nest value": {
+			"@context": ipld.Node{"name": ipld.Node{"@id": schemaOrg + "name", "@nest": "@id"}},
+		},
+		"invalid base direction": {
+			"@context": ipld.Node{"@direction": "up"},
+		},
+		"invalid @included value": {
+			"@context":  ipld.Node{"@vocab": schemaOrg},
+			"@included": []interface{}{ipld.Node{"@value": "Alice"}},
+		},
+	}
+
+	for code, doc := range tests {
+		_, err := Expand(doc, nil)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: expected a *Error, got %#v", code, err)
+			continue
+		}
+		if e.Code != code {
+			t.Errorf("%s: got error code %q", code, e.Code)
+		}
+	}
+}
+
+func TestExpandJsonLD11(t *testing.T) {
+	const ex = "http://example.org/"
+	tests := []struct {
+		doc      ipld.Node
+		expected []interface{}
+	}{
+		{
+			ipld.Node{"@context": ipld.Node{"@version": ipld.Float(1.1), "@vocab": schemaOrg}, "name": "Alice"},
+			[]interface{}{ipld.Node{schemaOrg + "name": []interface{}{ipld.Node{"@value": "Alice"}}}},
+		},
+		{ // property-scoped context
+			ipld.Node{
+				"@context": ipld.Node{"@vocab": schemaOrg, "knows": ipld.Node{"@context": ipld.Node{"name": ex + "name"}}},
+				"knows":    ipld.Node{"name": "Bob"},
+			},
+			[]interface{}{ipld.Node{schemaOrg + "knows": []interface{}{
+				ipld.Node{ex + "name": []interface{}{ipld.Node{"@value": "Bob"}}},
+			}}},
+		},
+		{ // type-scoped context, not propagated to nested nodes
+			ipld.Node{
+				"@context": ipld.Node{"@vocab": schemaOrg, "Person": ipld.Node{"@context": ipld.Node{"name": ex + "name"}}},
+				"@type":    "Person",
+				"name":     "Alice",
+				"knows":    ipld.Node{"name": "Bob"},
+			},
+			[]interface{}{ipld.Node{
+				"@type":     []interface{}{schemaOrg + "Person"},
+				ex + "name": []interface{}{ipld.Node{"@value": "Alice"}},
+				schemaOrg + "knows": []interface{}{
+					ipld.Node{schemaOrg + "name": []interface{}{ipld.Node{"@value": "Bob"}}},
+				},
+			}},
+		},
+		{ // protected terms may be defined again identically
+			ipld.Node{
+				"@context": []interface{}{
+					ipld.Node{"@protected": true, "name": schemaOrg + "name"},
+					ipld.Node{"name": schemaOrg + "name"},
+				},
+				"name": "Alice",
+			},
+			[]interface{}{ipld.Node{schemaOrg + "name": []interface{}{ipld.Node{"@value": "Alice"}}}},
+		},
+		{
+			ipld.Node{"@context": ipld.Node{"@vocab": schemaOrg, "details": "@nest"}, "details": ipld.Node{"name": "Alice"}},
+			[]interface{}{ipld.Node{schemaOrg + "name": []interface{}{ipld.Node{"@value": "Alice"}}}},
+		},
+		{
+			ipld.Node{
+				"@context":  ipld.Node{"@vocab": schemaOrg},
+				"@id":       ex + "alice",
+				"@included": []interface{}{ipld.Node{"@id": ex + "bob", "name": "Bob"}},
+			},
+			[]interface{}{ipld.Node{
+				"@id": ex + "alice",
+				"@included": []interface{}{ipld.Node{
+					"@id":              ex + "bob",
+					schemaOrg + "name": []interface{}{ipld.Node{"@value": "Bob"}},
+				}},
+			}},
+		},
+		{
+			ipld.Node{
+				"@context": ipld.Node{"data": ipld.Node{"@id": schemaOrg + "data", "@type": "@json"}},
+				"data":     ipld.Node{"b": []interface{}{ipld.Int(1), true}},
+			},
+			[]interface{}{ipld.Node{schemaOrg + "data": []interface{}{
+				ipld.Node{"@value": ipld.Node{"b": []interface{}{ipld.Int(1), true}}, "@type": "@json"},
+			}}},
+		},
+		{
+			ipld.Node{"@context": ipld.Node{"@vocab": schemaOrg, "@language": "ar", "@direction": "rtl"}, "name": "Alice"},
+			[]interface{}{ipld.Node{schemaOrg + "name": []interface{}{
+				ipld.Node{"@value": "Alice", "@language": "ar", "@direction": "rtl"},
+			}}},
+		},
+		{
+			ipld.Node{
+				"@context": ipld.Node{"@vocab": schemaOrg, "label": ipld.Node{"@id": schemaOrg + "name", "@container": "@language"}},
+				"label":    ipld.Node{"en": "Alice", "@none": "Alice"},
+			},
+			[]interface{}{ipld.Node{schemaOrg + "name": []interface{}{
+				ipld.Node{"@value": "Alice"},
+				ipld.Node{"@value": "Alice", "@language": "en"},
+			}}},
+		},
+		{
+			ipld.Node{
+				"@context": ipld.Node{"@vocab": schemaOrg, "byId": ipld.Node{"@id": schemaOrg + "knows", "@container": "@id"}},
+				"byId":     ipld.Node{ex + "bob": ipld.Node{"name": "Bob"}},
+			},
+			[]interface{}{ipld.Node{schemaOrg + "knows": []interface{}{ipld.Node{
+				"@id":              ex + "bob",
+				schemaOrg + "name": []interface{}{ipld.Node{"@value": "Bob"}},
+			}}}},
+		},
+		{
+			ipld.Node{
+				"@context": ipld.Node{"@vocab": schemaOrg, "byType": ipld.Node{"@id": schemaOrg + "knows", "@container": "@type"}},
+				"byType":   ipld.Node{"Person": ipld.Node{"name": "Bob"}, "@none": ipld.Node{"name": "Carol"}},
+			},
+			[]interface{}{ipld.Node{schemaOrg + "knows": []interface{}{
+				ipld.Node{schemaOrg + "name": []interface{}{ipld.Node{"@value": "Carol"}}},
+				ipld.Node{
+					"@type":            []interface{}{schemaOrg + "Person"},
+					schemaOrg + "name": []interface{}{ipld.Node{"@value": "Bob"}},
+				},
+			}}},
+		},
+		{
+			ipld.Node{
+				"@context": ipld.Node{"@vocab": schemaOrg, "byName": ipld.Node{"@id": schemaOrg + "knows", "@container": "@index", "@index": "name"}},
+				"byName":   ipld.Node{"Bob": ipld.Node{"@id": ex + "bob"}},
+			},
+			[]interface{}{ipld.Node{schemaOrg + "knows": []interface{}{ipld.Node{
+				"@id":              ex + "bob",
+				schemaOrg + "name": []interface{}{ipld.Node{"@value": "Bob"}},
+			}}}},
+		},
+		{
+			ipld.Node{
+				"@context": ipld.Node{"@vocab": schemaOrg, "claim": ipld.Node{"@container": []interface{}{"@graph", "@set"}}},
+				"claim":    ipld.Node{"name": "Bob"},
+			},
+			[]interface{}{ipld.Node{schemaOrg + "claim": []interface{}{ipld.Node{"@graph": []interface{}{
+				ipld.Node{schemaOrg + "name": []interface{}{ipld.Node{"@value": "Bob"}}},
+			}}}}},
+		},
+		{
+			ipld.Node{
+				"@context": ipld.Node{"@vocab": schemaOrg, "graphs": ipld.Node{"@id": schemaOrg + "claim", "@container": []interface{}{"@graph", "@id"}}},
+				"graphs":   ipld.Node{ex + "g": ipld.Node{"name": "Bob"}},
+			},
+			[]interface{}{ipld.Node{schemaOrg + "claim": []interface{}{ipld.Node{
+				"@id": ex + "g",
+				"@graph": []interface{}{
+					ipld.Node{schemaOrg + "name": []interface{}{ipld.Node{"@value": "Bob"}}},
+				},
+			}}}},
+		},
+	}
+
+	for i, test := range tests {
+		res, err := Expand(test.doc, nil)
+		if err != nil {
+			t.Errorf("#%d: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("#%d: expansion mismatch:\n%#v\n%#v", i, res, test.expected)
+		}
+	}
+}
+
+func TestRemoteContext(t *testing.T) {
+	opts := &Options{
+		Loader: MapContextLoader{
+			"http://example.org/context": ipld.Node{"@context": personContext},
+		},
+	}
+	doc := ipld.Node{
+		"@context": "http://example.org/context",
+		"knows":    "http://example.org/bob",
+	}
+
+	res, err := Expand(doc, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		ipld.Node{schemaOrg + "knows": []interface{}{ipld.Node{"@id": "http://example.org/bob"}}},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expansion mismatch:\n%#v\n%#v", res, expected)
+	}
+}
+
+func TestIPFSContextLoader(t *testing.T) {
+	s := store.NewMemoryStore()
+	schemas, err := s.Put(ipld.Node{
+		"person": ipld.Node{"@context": personContext},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := s.Put(ipld.Node{
+		"schemas": ipld.Node{"mlink": schemas.B58String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{
+		Loader: &IPFSContextLoader{Resolver: traverse.NewResolver(s)},
+	}
+	doc := ipld.Node{
+		"@context": "/ipfs/" + root.B58String() + "/schemas/person",
+		"knows":    "http://example.org/bob",
+	}
+
+	res, err := Expand(doc, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		ipld.Node{schemaOrg + "knows": []interface{}{ipld.Node{"@id": "http://example.org/bob"}}},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expansion mismatch:\n%#v\n%#v", res, expected)
+	}
+
+	doc["@context"] = "/ipfs/" + root.B58String() + "/schemas/missing"
+	if _, err := Expand(doc, opts); err == nil {
+		t.Error("expected an error loading a missing context")
+	}
+}
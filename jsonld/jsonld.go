@@ -1,6 +1,9 @@
 package jsonld
 
 import(
+	"sort"
+	"strings"
+
 	ipld "github.com/ipfs/go-ipld"
 )
 
@@ -68,7 +71,7 @@ func ToLinkedData(d ipld.Node) ipld.Node {
 //	"index-name": { "@container": "@index" }
 //
 func ToLinkedDataAll(d ipld.Node) ipld.Node {
-	res, err := ipld.Transform(d, func(root, curr ipld.Node, path []string, err error) (ipld.Node, error) {
+	res, err := ipld.Transform(d, func(root, curr ipld.Node, path ipld.Path, err error) (ipld.Node, error) {
 		return ToLinkedData(curr), err
 	})
 	if err != nil {
@@ -77,6 +80,143 @@ func ToLinkedDataAll(d ipld.Node) ipld.Node {
 	return res
 }
 
+// Like FromLinkedDataAll but on the root node only. The nodes in the indexed
+// container are not converted.
+func FromLinkedData(d ipld.Node, indexes ...string) ipld.Node {
+	index_name, index := findIndex(d, indexes)
+	return fromLinkedData(d, index_name, index)
+}
+
+// Reorganize JSON-LD data to the IPLD layout. This is the inverse of
+// ToLinkedDataAll: keys are escaped, and the first property of each node
+// whose name is listed in indexes and whose value is a node is nested back
+// as an indexed container, the other properties moving to "@attrs":
+//
+//	{
+//		"key": "value",
+//		"index-name": {
+//			"index": { ... }
+//		}
+//	}
+//
+// becomes:
+//
+//	{
+//		"@container": "@index",
+//		"@index": "index-name",
+//		"@attrs": {
+//			"key": "value",
+//		},
+//		"index": { ... }
+//	}
+//
+// The indexes are usually the terms defined with "@container": "@index" in
+// the context, see IndexTerms.
+//
+// For any d, ToLinkedDataAll(FromLinkedDataAll(d, indexes...)) is equal to
+// d. The reverse does not hold for indexed containers without an "@index"
+// name, which ToLinkedDataAll drops.
+func FromLinkedDataAll(d ipld.Node, indexes ...string) ipld.Node {
+	index_name, index := findIndex(d, indexes)
+
+	res := ipld.Node{}
+	for key, val := range d {
+		if index != nil && key == index_name {
+			sub := ipld.Node{}
+			for k, v := range index {
+				sub[k] = fromLinkedDataValue(v, indexes)
+			}
+			res[key] = sub
+		} else {
+			res[key] = fromLinkedDataValue(val, indexes)
+		}
+	}
+
+	return fromLinkedData(res, index_name, res[index_name])
+}
+
+func fromLinkedDataValue(v interface{}, indexes []string) interface{} {
+	switch val := v.(type) {
+	case ipld.Node:
+		return FromLinkedDataAll(val, indexes...)
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, item := range val {
+			res[i] = fromLinkedDataValue(item, indexes)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+func findIndex(d ipld.Node, indexes []string) (string, ipld.Node) {
+	for _, name := range indexes {
+		if index, ok := d[name].(ipld.Node); ok {
+			return name, index
+		}
+	}
+	return "", nil
+}
+
+func fromLinkedData(d ipld.Node, index_name string, index interface{}) ipld.Node {
+	res := ipld.Node{}
+
+	index_node, is_container := index.(ipld.Node)
+	if !is_container {
+		for key, val := range d {
+			if strings.HasPrefix(key, "@") {
+				res[key] = val
+			} else {
+				res[ipld.EscapePathComponent(key)] = val
+			}
+		}
+		return res
+	}
+
+	attrs := ipld.Node{}
+	for key, val := range d {
+		if key == index_name {
+			continue
+		} else if strings.HasPrefix(key, "@") {
+			res[key] = val
+		} else {
+			attrs[key] = val
+		}
+	}
+
+	for key, val := range index_node {
+		res[ipld.EscapePathComponent(key)] = val
+	}
+
+	res["@container"] = "@index"
+	res["@index"] = index_name
+	if len(attrs) > 0 {
+		res["@attrs"] = attrs
+	}
+	return res
+}
+
+// Returns the terms defined with "@container": "@index" in the JSON-LD
+// context ctx, suitable for FromLinkedDataAll. Remote contexts are loaded
+// with the loader in opts.
+func IndexTerms(ctx interface{}, opts *Options) ([]string, error) {
+	p := &processor{opts: opts.orDefault()}
+	active, err := p.parseContext(newActiveContext(p.opts.Base), ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []string
+	for term, def := range active.terms {
+		if def != nil && def.container.has("@index") {
+			terms = append(terms, term)
+		}
+	}
+	sort.Strings(terms)
+	return terms, nil
+}
+
 func copyNode(n ipld.Node) ipld.Node {
 	var res ipld.Node = ipld.Node{}
 	for k, v := range n {
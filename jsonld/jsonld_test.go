@@ -0,0 +1,101 @@
+package jsonld
+
+import (
+	"reflect"
+	"testing"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+func TestFromLinkedData(t *testing.T) {
+	for tci, tc := range testCases {
+		var indexes []string
+		if name, ok := tc.src["@index"].(string); ok {
+			indexes = append(indexes, name)
+		}
+
+		// JSON-LD -> IPLD -> JSON-LD
+		back := ToLinkedDataAll(FromLinkedDataAll(tc.jsonld, indexes...))
+		if !reflect.DeepEqual(tc.jsonld, back) {
+			t.Errorf("#%d: JSON-LD round-trip mismatch.\nGot:    %#v\nExpect: %#v", tci, back, tc.jsonld)
+		}
+
+		// IPLD -> JSON-LD -> IPLD -> JSON-LD
+		jsonld := ToLinkedDataAll(tc.src)
+		back = ToLinkedDataAll(FromLinkedDataAll(jsonld, indexes...))
+		if !reflect.DeepEqual(jsonld, back) {
+			t.Errorf("#%d: IPLD round-trip mismatch.\nGot:    %#v\nExpect: %#v", tci, back, jsonld)
+		}
+	}
+
+	// the first two test cases are in the canonical IPLD layout
+	for tci, tc := range testCases[:2] {
+		var indexes []string
+		if name, ok := tc.src["@index"].(string); ok {
+			indexes = append(indexes, name)
+		}
+		res := FromLinkedDataAll(ToLinkedDataAll(tc.src), indexes...)
+		if !reflect.DeepEqual(tc.src, res) {
+			t.Errorf("#%d: IPLD version mismatch.\nGot:    %#v\nExpect: %#v", tci, res, tc.src)
+		}
+	}
+}
+
+func TestFromLinkedDataEscaping(t *testing.T) {
+	expected := ipld.Node{
+		"@attrs": ipld.Node{
+			"attr": "val",
+		},
+		"@type":      "commit",
+		"@context":   "/ipfs/QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo/mdag",
+		"@container": "@index",
+		"@index":     "files",
+		"foo":        "bar",
+		"baz": ipld.Node{
+			"foobar": "barfoo",
+			"mlink":  "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo",
+		},
+		"\\@bazz": ipld.Node{
+			"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo",
+		},
+		"bar/ra\\\\b": ipld.Node{
+			"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPb",
+		},
+		"bar": ipld.Node{},
+	}
+
+	res := FromLinkedDataAll(testCases[2].jsonld, "files")
+	if !reflect.DeepEqual(expected, res) {
+		t.Errorf("IPLD version mismatch.\nGot:    %#v\nExpect: %#v", res, expected)
+	}
+
+	root := FromLinkedData(ipld.Node{
+		"files": ipld.Node{
+			"a": ipld.Node{"@b": "c"},
+		},
+	}, "files")
+	expected = ipld.Node{
+		"@container": "@index",
+		"@index":     "files",
+		"a":          ipld.Node{"@b": "c"},
+	}
+	if !reflect.DeepEqual(expected, root) {
+		t.Errorf("root conversion mismatch.\nGot:    %#v\nExpect: %#v", root, expected)
+	}
+}
+
+func TestIndexTerms(t *testing.T) {
+	ctx := ipld.Node{
+		"@vocab": "http://example.org/",
+		"files":  ipld.Node{"@id": "http://example.org/files", "@container": "@index"},
+		"links":  ipld.Node{"@container": "@index"},
+		"title":  "http://purl.org/dc/terms/title",
+	}
+	terms, err := IndexTerms(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(terms, []string{"files", "links"}) {
+		t.Errorf("got index terms %v", terms)
+	}
+}
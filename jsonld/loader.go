@@ -0,0 +1,80 @@
+package jsonld
+
+import (
+	"errors"
+	"strings"
+
+	ipld "github.com/ipfs/go-ipld"
+	traverse "github.com/ipfs/go-ipld/traverse"
+)
+
+// ErrContextNotFound is returned by context loaders which do not know a
+// context.
+var ErrContextNotFound = errors.New("context not found")
+
+// ContextLoader retrieves remote contexts. The returned document must
+// contain the context under the "@context" key.
+type ContextLoader interface {
+	LoadContext(iri string) (interface{}, error)
+}
+
+// ContextLoaderFunc is an adapter to allow the use of ordinary functions
+// as ContextLoader.
+type ContextLoaderFunc func(iri string) (interface{}, error)
+
+// LoadContext calls f(iri).
+func (f ContextLoaderFunc) LoadContext(iri string) (interface{}, error) {
+	return f(iri)
+}
+
+// MapContextLoader serves context documents from memory, keyed by IRI.
+type MapContextLoader map[string]interface{}
+
+// LoadContext returns the document registered for iri.
+func (m MapContextLoader) LoadContext(iri string) (interface{}, error) {
+	doc, ok := m[iri]
+	if !ok {
+		return nil, ErrContextNotFound
+	}
+	return doc, nil
+}
+
+// IPFSContextLoader loads contexts stored as IPLD nodes, referenced by
+// paths such as:
+//
+//   /ipfs/Qmf1ec6n9f8kW8JTLjqaZceJVpDpZD4L3aPoJFvssBE7Eb/merkleweb
+//
+// or by IRIs of linked blocks (see LinkIRI), optionally followed by a path.
+// The path is resolved across links with Resolver. IRIs which are not
+// /ipfs/ paths are passed to Fallback, if set.
+type IPFSContextLoader struct {
+	Resolver *traverse.Resolver
+	Fallback ContextLoader
+}
+
+// LoadContext resolves iri and returns the node found.
+func (l *IPFSContextLoader) LoadContext(iri string) (interface{}, error) {
+	p := iri
+	if strings.HasPrefix(p, LinkIRIPrefix) {
+		p = strings.TrimPrefix(p[len(LinkIRIPrefix):], "/ipfs/")
+		p = "/ipfs/" + p
+	}
+	if !strings.HasPrefix(p, "/ipfs/") {
+		if l.Fallback != nil {
+			return l.Fallback.LoadContext(iri)
+		}
+		return nil, ErrContextNotFound
+	}
+
+	v, _, rest, err := l.Resolver.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, ErrContextNotFound
+	}
+	if _, ok := v.(ipld.Node); !ok {
+		return nil, ErrContextNotFound
+	}
+	return v, nil
+}
@@ -0,0 +1,311 @@
+package jsonld
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// String returns the N-Quads representation of t.
+func (t Term) String() string {
+	switch t.Kind {
+	case BlankNode:
+		return t.Value
+	case Literal:
+		s := `"` + escapeLiteral(t.Value) + `"`
+		if t.Language != "" {
+			return s + "@" + t.Language
+		}
+		if t.Datatype != "" && t.Datatype != XSDString {
+			return s + "^^<" + t.Datatype + ">"
+		}
+		return s
+	}
+	return "<" + t.Value + ">"
+}
+
+// String returns q as an N-Quads statement, without the line terminator.
+func (q Quad) String() string {
+	s := q.Subject.String() + " " + q.Predicate.String() + " " + q.Object.String()
+	if q.Graph.Value != "" {
+		s += " " + q.Graph.String()
+	}
+	return s + " ."
+}
+
+func escapeLiteral(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return r.Replace(s)
+}
+
+// WriteNQuads writes quads to w in the N-Quads format, one per line.
+func WriteNQuads(w io.Writer, quads []Quad) error {
+	bw := bufio.NewWriter(w)
+	for _, q := range quads {
+		if _, err := bw.WriteString(q.String() + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// NQuadsError reports a syntax error in an N-Quads document.
+type NQuadsError struct {
+	Line   int
+	Reason string
+}
+
+func (e *NQuadsError) Error() string {
+	return fmt.Sprintf("nquads: line %d: %s", e.Line, e.Reason)
+}
+
+// ParseNQuads reads an N-Quads document. Literals without datatype are
+// given the xsd:string datatype.
+func ParseNQuads(r io.Reader) ([]Quad, error) {
+	var quads []Quad
+
+	// lines are read whole, as literals may be arbitrarily long.
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		s, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if s == "" && err == io.EOF {
+			return quads, nil
+		}
+
+		p := &nquadsParser{s: strings.TrimRight(s, "\r\n")}
+		p.skipSpace()
+		if !p.eof() && p.peek() != '#' {
+			q, qerr := p.quad()
+			if qerr != nil {
+				return nil, &NQuadsError{line, qerr.Error()}
+			}
+			quads = append(quads, q)
+		}
+		if err == io.EOF {
+			return quads, nil
+		}
+	}
+}
+
+// nquadsParser parses a single N-Quads line.
+type nquadsParser struct {
+	s   string
+	pos int
+}
+
+func (p *nquadsParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *nquadsParser) peek() byte {
+	return p.s[p.pos]
+}
+
+func (p *nquadsParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *nquadsParser) quad() (Quad, error) {
+	var q Quad
+	var err error
+
+	if q.Subject, err = p.term(); err != nil {
+		return q, err
+	}
+	if q.Predicate, err = p.term(); err != nil {
+		return q, err
+	}
+	if q.Object, err = p.term(); err != nil {
+		return q, err
+	}
+
+	p.skipSpace()
+	if !p.eof() && p.peek() != '.' {
+		if q.Graph, err = p.term(); err != nil {
+			return q, err
+		}
+		p.skipSpace()
+	}
+
+	if p.eof() || p.peek() != '.' {
+		return q, fmt.Errorf("expected '.' at column %d", p.pos+1)
+	}
+	p.pos++
+	p.skipSpace()
+	if !p.eof() && p.peek() != '#' {
+		return q, fmt.Errorf("unexpected data at column %d", p.pos+1)
+	}
+
+	switch {
+	case q.Subject.Kind == Literal:
+		return q, fmt.Errorf("literal subject")
+	case q.Predicate.Kind != IRI:
+		return q, fmt.Errorf("predicate is not an IRI")
+	case q.Graph.Kind == Literal:
+		return q, fmt.Errorf("literal graph label")
+	}
+	return q, nil
+}
+
+func (p *nquadsParser) term() (Term, error) {
+	p.skipSpace()
+	if p.eof() {
+		return Term{}, fmt.Errorf("unexpected end of line")
+	}
+
+	switch p.peek() {
+	case '<':
+		iri, err := p.iri()
+		return Term{Kind: IRI, Value: iri}, err
+
+	case '_':
+		if !strings.HasPrefix(p.s[p.pos:], "_:") {
+			break
+		}
+		start := p.pos
+		for !p.eof() && !strings.ContainsRune(" \t<\"", rune(p.peek())) {
+			p.pos++
+		}
+		// a blank node label cannot end with a dot
+		for p.s[p.pos-1] == '.' {
+			p.pos--
+		}
+		if p.pos-start <= 2 {
+			return Term{}, fmt.Errorf("empty blank node label at column %d", start+1)
+		}
+		return Term{Kind: BlankNode, Value: p.s[start:p.pos]}, nil
+
+	case '"':
+		value, err := p.literal()
+		if err != nil {
+			return Term{}, err
+		}
+		t := Term{Kind: Literal, Value: value, Datatype: XSDString}
+		if strings.HasPrefix(p.s[p.pos:], "^^") {
+			p.pos += 2
+			if p.eof() || p.peek() != '<' {
+				return Term{}, fmt.Errorf("expected datatype IRI at column %d", p.pos+1)
+			}
+			t.Datatype, err = p.iri()
+		} else if !p.eof() && p.peek() == '@' {
+			p.pos++
+			start := p.pos
+			for !p.eof() && (isAlnum(p.peek()) || p.peek() == '-') {
+				p.pos++
+			}
+			if p.pos == start {
+				return Term{}, fmt.Errorf("empty language tag at column %d", start+1)
+			}
+			t.Datatype = RDFLangString
+			t.Language = p.s[start:p.pos]
+		}
+		return t, err
+	}
+	return Term{}, fmt.Errorf("unexpected character %q at column %d", p.peek(), p.pos+1)
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *nquadsParser) iri() (string, error) {
+	p.pos++ // '<'
+	var buf []byte
+	for !p.eof() {
+		c := p.peek()
+		switch c {
+		case '>':
+			p.pos++
+			return string(buf), nil
+		case '\\':
+			r, err := p.escape(false)
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, string(r)...)
+		default:
+			buf = append(buf, c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated IRI")
+}
+
+func (p *nquadsParser) literal() (string, error) {
+	p.pos++ // '"'
+	var buf []byte
+	for !p.eof() {
+		c := p.peek()
+		switch c {
+		case '"':
+			p.pos++
+			return string(buf), nil
+		case '\\':
+			r, err := p.escape(true)
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, string(r)...)
+		default:
+			buf = append(buf, c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated literal")
+}
+
+// escape decodes the escape sequence at the current position. Only
+// unicode escapes are allowed in IRIs.
+func (p *nquadsParser) escape(literal bool) (rune, error) {
+	start := p.pos
+	p.pos++ // '\'
+	if p.eof() {
+		return 0, fmt.Errorf("unterminated escape sequence")
+	}
+
+	c := p.peek()
+	p.pos++
+	if literal {
+		switch c {
+		case 't':
+			return '\t', nil
+		case 'b':
+			return '\b', nil
+		case 'n':
+			return '\n', nil
+		case 'r':
+			return '\r', nil
+		case 'f':
+			return '\f', nil
+		case '"', '\'', '\\':
+			return rune(c), nil
+		}
+	}
+
+	size := 0
+	switch c {
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		return 0, fmt.Errorf("invalid escape sequence at column %d", start+1)
+	}
+	if p.pos+size > len(p.s) {
+		return 0, fmt.Errorf("invalid escape sequence at column %d", start+1)
+	}
+	n, err := strconv.ParseUint(p.s[p.pos:p.pos+size], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return 0, fmt.Errorf("invalid escape sequence at column %d", start+1)
+	}
+	p.pos += size
+	return rune(n), nil
+}
@@ -0,0 +1,761 @@
+package jsonld
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+	traverse "github.com/ipfs/go-ipld/traverse"
+)
+
+// IRIs of the RDF and XML Schema vocabularies used in the conversions.
+const (
+	RDFType       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	RDFFirst      = "http://www.w3.org/1999/02/22-rdf-syntax-ns#first"
+	RDFRest       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#rest"
+	RDFNil        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#nil"
+	RDFLangString = "http://www.w3.org/1999/02/22-rdf-syntax-ns#langString"
+	RDFJSON       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#JSON"
+	XSDString     = "http://www.w3.org/2001/XMLSchema#string"
+	XSDBoolean    = "http://www.w3.org/2001/XMLSchema#boolean"
+	XSDInteger    = "http://www.w3.org/2001/XMLSchema#integer"
+	XSDDouble     = "http://www.w3.org/2001/XMLSchema#double"
+)
+
+// ErrInvalidQuad is returned when a quad has a literal subject or graph,
+// or a predicate which is not an IRI.
+var ErrInvalidQuad = errors.New("invalid quad")
+
+// LinkIRIPrefix is prepended to the hash of merkle-links to form the IRI
+// of the linked block.
+const LinkIRIPrefix = "ipfs:"
+
+// TermKind is the kind of an RDF term.
+type TermKind int
+
+const (
+	IRI TermKind = iota
+	BlankNode
+	Literal
+)
+
+// Term is an RDF term. The Value of blank nodes includes the "_:" prefix.
+// Datatype and Language are only set on literals.
+type Term struct {
+	Kind     TermKind
+	Value    string
+	Datatype string
+	Language string
+}
+
+// Quad is an RDF statement. Graph is the zero Term for statements of the
+// default graph.
+type Quad struct {
+	Subject   Term
+	Predicate Term
+	Object    Term
+	Graph     Term
+}
+
+// LinkIRI returns the IRI identifying the target of a merkle-link.
+func LinkIRI(l ipld.Link) string {
+	return LinkIRIPrefix + l.LinkStr()
+}
+
+// rdfState holds the blank node labels issued during a conversion.
+type rdfState struct {
+	opts    *Options
+	counter int
+	labels  map[string]string
+}
+
+// blankNode returns the new label of the blank node old, or a fresh label
+// if old is empty.
+func (s *rdfState) blankNode(old string) string {
+	if l, ok := s.labels[old]; ok && old != "" {
+		return l
+	}
+	l := "_:b" + strconv.Itoa(s.counter)
+	s.counter++
+	if old != "" {
+		s.labels[old] = l
+	}
+	return l
+}
+
+// ToRDF converts n to RDF quads. n is first converted to JSON-LD with
+// ToLinkedDataAll and merkle-links are replaced with references to their
+// IRI, as given by LinkIRI. Other properties of the links become
+// statements about the linked block.
+//
+// As in JSON-LD, keys which are not mapped to an IRI by a context are
+// dropped. Nodes without "@context" can be mapped with the ExpandContext
+// option, for instance to a "@vocab".
+func ToRDF(n ipld.Node, opts *Options) ([]Quad, error) {
+	s := &rdfState{opts: opts.orDefault()}
+	return s.toRDF(n)
+}
+
+// ToRDFAll converts the block h, and all the blocks reachable from it
+// through merkle-links, to RDF quads. Each block is converted with ToRDF,
+// and the root node of a block is identified by its IRI unless it has an
+// "@id".
+func ToRDFAll(loader traverse.Loader, h mh.Multihash, opts *Options) ([]Quad, error) {
+	s := &rdfState{opts: opts.orDefault()}
+
+	var quads []Quad
+	seen := map[string]bool{}
+	queue := []mh.Multihash{h}
+	for len(queue) > 0 {
+		h, queue = queue[0], queue[1:]
+		key := h.B58String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		n, err := traverse.Load(loader, h)
+		if err != nil {
+			return nil, err
+		}
+
+		links := n.Links()
+		paths := make([]string, 0, len(links))
+		for path := range links {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			lh, err := links[path].Hash()
+			if err != nil {
+				return nil, err
+			}
+			queue = append(queue, lh)
+		}
+
+		root := copyNode(n)
+		if _, ok := root["@id"]; !ok {
+			root["@id"] = LinkIRIPrefix + key
+		}
+		q, err := s.toRDF(root)
+		if err != nil {
+			return nil, err
+		}
+		quads = append(quads, q...)
+	}
+	return quads, nil
+}
+
+func (s *rdfState) toRDF(n ipld.Node) ([]Quad, error) {
+	doc, err := ipld.Transform(ToLinkedDataAll(n), func(root, curr ipld.Node, path ipld.Path, err error) (ipld.Node, error) {
+		l, ok := ipld.LinkCast(curr)
+		if !ok {
+			return curr, err
+		}
+		res := ipld.Node{}
+		for k, v := range l {
+			if k != ipld.LinkKey {
+				res[k] = v
+			}
+		}
+		res["@id"] = LinkIRI(l)
+		return res, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expanded, err := Expand(doc, s.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.labels = map[string]string{}
+	graphs := map[string]map[string]ipld.Node{"@default": {}}
+	s.nodeMap(expanded, graphs, "@default", "", nil, "", nil)
+	return s.quads(graphs), nil
+}
+
+// nodeMap flattens the expanded element into the node objects of graphs,
+// indexed by graph name and subject. Blank nodes are relabeled.
+func (s *rdfState) nodeMap(elem interface{}, graphs map[string]map[string]ipld.Node, graph, subject string, reverse ipld.Node, prop string, list *[]interface{}) {
+	if items, ok := elem.([]interface{}); ok {
+		for _, item := range items {
+			s.nodeMap(item, graphs, graph, subject, reverse, prop, list)
+		}
+		return
+	}
+
+	m, ok := elem.(ipld.Node)
+	if !ok {
+		return
+	}
+	g := graphs[graph]
+	if g == nil {
+		g = map[string]ipld.Node{}
+		graphs[graph] = g
+	}
+
+	if _, ok := m["@value"]; ok {
+		if list != nil {
+			*list = append(*list, m)
+		} else if subject != "" {
+			addUnique(g[subject], prop, m)
+		}
+		return
+	}
+
+	if l, ok := m["@list"]; ok {
+		items := []interface{}{}
+		s.nodeMap(l, graphs, graph, subject, nil, prop, &items)
+		value := ipld.Node{"@list": items}
+		if list != nil {
+			*list = append(*list, value)
+		} else if subject != "" {
+			addUnique(g[subject], prop, value)
+		}
+		return
+	}
+
+	id, _ := m["@id"].(string)
+	if id == "" || strings.HasPrefix(id, "_:") {
+		id = s.blankNode(id)
+	}
+	node, ok := g[id]
+	if !ok {
+		node = ipld.Node{"@id": id}
+		g[id] = node
+	}
+
+	ref := ipld.Node{"@id": id}
+	if reverse != nil {
+		addUnique(node, prop, reverse)
+	} else if prop != "" {
+		if list != nil {
+			*list = append(*list, ref)
+		} else {
+			addUnique(g[subject], prop, ref)
+		}
+	}
+
+	for _, key := range sortedKeys(m) {
+		value := m[key]
+		switch key {
+		case "@id":
+		case "@type":
+			for _, t := range arrayify(value) {
+				ts, _ := t.(string)
+				if strings.HasPrefix(ts, "_:") {
+					ts = s.blankNode(ts)
+				}
+				addUnique(node, key, ts)
+			}
+		case "@index":
+			node[key] = value
+		case "@reverse":
+			rm, _ := value.(ipld.Node)
+			for _, rprop := range sortedKeys(rm) {
+				s.nodeMap(rm[rprop], graphs, graph, "", ref, rprop, nil)
+			}
+		case "@graph":
+			s.nodeMap(value, graphs, id, "", nil, "", nil)
+		case "@included":
+			s.nodeMap(value, graphs, graph, "", nil, "", nil)
+		default:
+			if strings.HasPrefix(key, "_:") {
+				continue // blank node properties are not valid RDF
+			}
+			if _, ok := node[key]; !ok {
+				node[key] = []interface{}{}
+			}
+			s.nodeMap(value, graphs, graph, id, nil, key, nil)
+		}
+	}
+}
+
+// addUnique appends value to the values of key in m, unless it is already
+// present.
+func addUnique(m ipld.Node, key string, value interface{}) {
+	values, _ := m[key].([]interface{})
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return
+		}
+	}
+	m[key] = append(values, value)
+}
+
+func sortedGraphKeys(m map[string]map[string]ipld.Node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedNodeKeys(m map[string]ipld.Node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quads returns the statements of the node objects in graphs.
+func (s *rdfState) quads(graphs map[string]map[string]ipld.Node) []Quad {
+	quads := []Quad{}
+	for _, graph := range sortedGraphKeys(graphs) {
+		var gterm Term
+		if graph != "@default" {
+			var ok bool
+			if gterm, ok = iriTerm(graph); !ok {
+				continue
+			}
+		}
+
+		g := graphs[graph]
+		for _, subject := range sortedNodeKeys(g) {
+			sterm, ok := iriTerm(subject)
+			if !ok {
+				continue
+			}
+
+			node := g[subject]
+			for _, prop := range sortedKeys(node) {
+				values, _ := node[prop].([]interface{})
+
+				if prop == "@type" {
+					for _, t := range values {
+						if oterm, ok := iriTerm(t.(string)); ok {
+							quads = append(quads, Quad{sterm, Term{Kind: IRI, Value: RDFType}, oterm, gterm})
+						}
+					}
+					continue
+				}
+
+				pterm, ok := iriTerm(prop)
+				if isKeyword(prop) || !ok || pterm.Kind == BlankNode {
+					continue
+				}
+
+				for _, item := range values {
+					if isList(item) {
+						items, _ := item.(ipld.Node)["@list"].([]interface{})
+						head, list := s.listToRDF(items, gterm)
+						quads = append(quads, Quad{sterm, pterm, head, gterm})
+						quads = append(quads, list...)
+					} else if oterm, ok := objectToRDF(item); ok {
+						quads = append(quads, Quad{sterm, pterm, oterm, gterm})
+					}
+				}
+			}
+		}
+	}
+	return quads
+}
+
+// listToRDF returns the head of the RDF collection holding items, and the
+// statements which describe it.
+func (s *rdfState) listToRDF(items []interface{}, graph Term) (Term, []Quad) {
+	if len(items) == 0 {
+		return Term{Kind: IRI, Value: RDFNil}, nil
+	}
+
+	var quads []Quad
+	nodes := make([]Term, len(items))
+	for i := range nodes {
+		nodes[i] = Term{Kind: BlankNode, Value: s.blankNode("")}
+	}
+	for i, item := range items {
+		if oterm, ok := objectToRDF(item); ok {
+			quads = append(quads, Quad{nodes[i], Term{Kind: IRI, Value: RDFFirst}, oterm, graph})
+		}
+		rest := Term{Kind: IRI, Value: RDFNil}
+		if i+1 < len(nodes) {
+			rest = nodes[i+1]
+		}
+		quads = append(quads, Quad{nodes[i], Term{Kind: IRI, Value: RDFRest}, rest, graph})
+	}
+	return nodes[0], quads
+}
+
+// iriTerm returns the term for an IRI or blank node identifier. Relative
+// IRIs cannot be represented.
+func iriTerm(id string) (Term, bool) {
+	switch {
+	case strings.HasPrefix(id, "_:"):
+		return Term{Kind: BlankNode, Value: id}, true
+	case isAbsoluteIRI(id):
+		return Term{Kind: IRI, Value: id}, true
+	}
+	return Term{}, false
+}
+
+// objectToRDF converts a value object or a node reference to a term.
+func objectToRDF(item interface{}) (Term, bool) {
+	m, ok := item.(ipld.Node)
+	if !ok {
+		return Term{}, false
+	}
+	if id, ok := m["@id"].(string); ok {
+		return iriTerm(id)
+	}
+
+	dt, _ := m["@type"].(string)
+	if dt == "@json" {
+		v, err := canonicalJSON(m["@value"])
+		if err != nil {
+			return Term{}, false
+		}
+		return Term{Kind: Literal, Value: v, Datatype: RDFJSON}, true
+	}
+	t := Term{Kind: Literal, Datatype: dt}
+
+	switch v := m["@value"].(type) {
+	case bool:
+		t.Value = strconv.FormatBool(v)
+		if dt == "" {
+			t.Datatype = XSDBoolean
+		}
+	case ipld.Float:
+		// floats of the data model are doubles, even when integral.
+		t.Value = canonicalDouble(float64(v))
+		if dt == "" {
+			t.Datatype = XSDDouble
+		}
+	case *big.Int:
+		t.Value = v.String()
+		if dt == "" {
+			t.Datatype = XSDInteger
+		}
+	case string:
+		t.Value = v
+		if lang, ok := m["@language"].(string); ok {
+			t.Datatype = RDFLangString
+			t.Language = lang
+		} else if dt == "" {
+			t.Datatype = XSDString
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if dt == XSDDouble {
+				t.Value = canonicalDouble(float64(rv.Int()))
+			} else {
+				t.Value = strconv.FormatInt(rv.Int(), 10)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if dt == XSDDouble {
+				t.Value = canonicalDouble(float64(rv.Uint()))
+			} else {
+				t.Value = strconv.FormatUint(rv.Uint(), 10)
+			}
+		case reflect.Float32, reflect.Float64:
+			f := rv.Float()
+			if f == math.Trunc(f) && math.Abs(f) < 1e21 && dt != XSDDouble {
+				t.Value = strconv.FormatFloat(f, 'f', -1, 64)
+			} else {
+				t.Value = canonicalDouble(f)
+				if dt == "" {
+					dt = XSDDouble
+				}
+			}
+		default:
+			return Term{}, false
+		}
+		if dt == "" {
+			dt = XSDInteger
+		}
+		t.Datatype = dt
+	}
+	return t, true
+}
+
+// canonicalJSON returns the JSON literal v in the canonical form of
+// rdf:JSON literals: object keys are sorted and there is no whitespace.
+func canonicalJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(&buf, v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) error {
+	if m, ok := asMap(v); ok {
+		buf.WriteByte('{')
+		for i, k := range sortedKeys(m) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, m[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	if s, ok := asSlice(v); ok {
+		buf.WriteByte('[')
+		for i, item := range s {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	switch n := v.(type) {
+	case ipld.Float:
+		v = float64(n)
+	case float32:
+		v = float64(n)
+	}
+	if f, ok := v.(float64); ok {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return ipld.ErrInvalidFloat
+		}
+		if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			buf.WriteString(strconv.FormatFloat(f, 'e', -1, 64))
+		} else {
+			buf.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+		}
+		return nil
+	}
+
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // newline added by Encode
+	return nil
+}
+
+// parseJSONLiteral decodes the value of an rdf:JSON literal.
+func parseJSONLiteral(s string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return fromJSONLiteral(v), nil
+}
+
+func fromJSONLiteral(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		n := make(ipld.Node, len(v))
+		for k, item := range v {
+			n[k] = fromJSONLiteral(item)
+		}
+		return n
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromJSONLiteral(item)
+		}
+		return v
+	case json.Number:
+		if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return ipld.BigInt(i)
+		}
+		f, _ := v.Float64()
+		return ipld.Float(f)
+	}
+	return v
+}
+
+// canonicalDouble formats f like 1.5E3, the canonical form of xsd:double.
+func canonicalDouble(f float64) string {
+	s := strconv.FormatFloat(f, 'E', 15, 64)
+	i := strings.Index(s, "E")
+	mantissa := strings.TrimRight(s[:i], "0")
+	if strings.HasSuffix(mantissa, ".") {
+		mantissa += "0"
+	}
+	exp, _ := strconv.Atoi(s[i+1:])
+	return mantissa + "E" + strconv.Itoa(exp)
+}
+
+// FromRDF converts RDF quads to a list of nodes in the expanded JSON-LD
+// form, the inverse of ToRDF: references to IRIs starting with
+// LinkIRIPrefix are converted back to merkle-links, and well-formed RDF
+// collections to "@list" objects. Named graphs are stored under "@graph"
+// in the node named after them.
+func FromRDF(quads []Quad, opts *Options) ([]interface{}, error) {
+	opts = opts.orDefault()
+
+	graphs := map[string]map[string]ipld.Node{"@default": {}}
+	usages := map[string]int{}
+	for _, q := range quads {
+		if q.Subject.Kind == Literal || q.Predicate.Kind != IRI || q.Graph.Kind == Literal {
+			return nil, ErrInvalidQuad
+		}
+
+		name := "@default"
+		if q.Graph.Value != "" {
+			name = q.Graph.Value
+			if _, ok := graphs["@default"][name]; !ok {
+				graphs["@default"][name] = ipld.Node{"@id": name}
+			}
+		}
+
+		g := graphs[name]
+		if g == nil {
+			g = map[string]ipld.Node{}
+			graphs[name] = g
+		}
+		node, ok := g[q.Subject.Value]
+		if !ok {
+			node = ipld.Node{"@id": q.Subject.Value}
+			g[q.Subject.Value] = node
+		}
+
+		switch {
+		case q.Object.Kind == Literal:
+			addUnique(node, q.Predicate.Value, rdfToObject(q.Object, opts.UseNativeTypes))
+
+		case q.Predicate.Value == RDFType:
+			addUnique(node, "@type", q.Object.Value)
+
+		case q.Object.Kind == IRI && strings.HasPrefix(q.Object.Value, LinkIRIPrefix):
+			addUnique(node, q.Predicate.Value, ipld.Node{ipld.LinkKey: q.Object.Value[len(LinkIRIPrefix):]})
+
+		default:
+			addUnique(node, q.Predicate.Value, ipld.Node{"@id": q.Object.Value})
+			usages[name+" "+q.Object.Value]++
+		}
+	}
+
+	for name, g := range graphs {
+		convertLists(g, func(id string) int { return usages[name+" "+id] })
+	}
+
+	res := []interface{}{}
+	def := graphs["@default"]
+	for _, subject := range sortedNodeKeys(def) {
+		node := def[subject]
+		if g, ok := graphs[subject]; ok && subject != "@default" {
+			nodes := []interface{}{}
+			for _, s := range sortedNodeKeys(g) {
+				nodes = append(nodes, g[s])
+			}
+			node["@graph"] = nodes
+		}
+		res = append(res, node)
+	}
+	return res, nil
+}
+
+// convertLists replaces the references to well-formed RDF collections in g
+// with "@list" objects, and removes the collection nodes.
+func convertLists(g map[string]ipld.Node, usages func(id string) int) {
+	isListNode := func(id string) bool {
+		n, ok := g[id]
+		if !ok || !strings.HasPrefix(id, "_:") || usages(id) != 1 || len(n) != 3 {
+			return false
+		}
+		first, _ := n[RDFFirst].([]interface{})
+		rest, _ := n[RDFRest].([]interface{})
+		return len(first) == 1 && len(rest) == 1
+	}
+
+	for _, subject := range sortedNodeKeys(g) {
+		node, ok := g[subject]
+		if !ok {
+			continue
+		}
+		for prop, v := range node {
+			values, ok := v.([]interface{})
+			if !ok || prop == RDFRest || prop == RDFFirst {
+				continue
+			}
+			for i, value := range values {
+				ref, ok := value.(ipld.Node)
+				if !ok || len(ref) != 1 {
+					continue
+				}
+				id, _ := ref["@id"].(string)
+				if id == RDFNil {
+					values[i] = ipld.Node{"@list": []interface{}{}}
+					continue
+				}
+
+				var items []interface{}
+				var chain []string
+				for id != RDFNil && isListNode(id) {
+					n := g[id]
+					items = append(items, n[RDFFirst].([]interface{})[0])
+					chain = append(chain, id)
+					rest, _ := n[RDFRest].([]interface{})[0].(ipld.Node)
+					id, _ = rest["@id"].(string)
+				}
+				if id != RDFNil || len(chain) == 0 {
+					continue // not a well-formed list
+				}
+
+				values[i] = ipld.Node{"@list": items}
+				for _, id := range chain {
+					delete(g, id)
+				}
+			}
+		}
+	}
+}
+
+// rdfToObject converts a literal to a value object, or to a native value
+// if native is true and the datatype allows it.
+func rdfToObject(t Term, native bool) ipld.Node {
+	if t.Datatype == RDFLangString || t.Language != "" {
+		return ipld.Node{"@value": t.Value, "@language": t.Language}
+	}
+
+	if t.Datatype == RDFJSON {
+		if v, err := parseJSONLiteral(t.Value); err == nil {
+			return ipld.Node{"@value": v, "@type": "@json"}
+		}
+	}
+
+	if native {
+		switch t.Datatype {
+		case XSDBoolean:
+			if b, err := strconv.ParseBool(t.Value); err == nil && (t.Value == "true" || t.Value == "false") {
+				return ipld.Node{"@value": b}
+			}
+		case XSDInteger:
+			if i, ok := new(big.Int).SetString(t.Value, 10); ok {
+				return ipld.Node{"@value": ipld.BigInt(i)}
+			}
+		case XSDDouble:
+			if f, err := strconv.ParseFloat(t.Value, 64); err == nil {
+				return ipld.Node{"@value": ipld.Float(f)}
+			}
+		}
+	}
+
+	if t.Datatype == "" || t.Datatype == XSDString {
+		return ipld.Node{"@value": t.Value}
+	}
+	return ipld.Node{"@value": t.Value, "@type": t.Datatype}
+}
@@ -0,0 +1,225 @@
+package jsonld
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	ipld "github.com/ipfs/go-ipld"
+	store "github.com/ipfs/go-ipld/store"
+)
+
+const (
+	testHashA = "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"
+	testHashB = "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPb"
+)
+
+var exampleVocab = ipld.Node{"@vocab": "http://example.org/"}
+
+func TestToRDF(t *testing.T) {
+	n := ipld.Node{
+		"@id":    "http://example.org/commit",
+		"@type":  "Commit",
+		"title":  "first \"commit\"\n",
+		"size":   42,
+		"ratio":  0.5,
+		"signed": true,
+		"parent": ipld.Node{"mlink": testHashA, "name": "parent"},
+		"tags":   ipld.Node{"@list": []interface{}{"a", "b"}},
+		"author": ipld.Node{"name": "Alice"},
+	}
+
+	quads, err := ToRDF(n, &Options{ExpandContext: exampleVocab})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		`_:b0 <http://example.org/name> "Alice" .`,
+		`<http://example.org/commit> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Commit> .`,
+		`<http://example.org/commit> <http://example.org/author> _:b0 .`,
+		`<http://example.org/commit> <http://example.org/parent> <ipfs:` + testHashA + `> .`,
+		`<http://example.org/commit> <http://example.org/ratio> "5.0E-1"^^<http://www.w3.org/2001/XMLSchema#double> .`,
+		`<http://example.org/commit> <http://example.org/signed> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .`,
+		`<http://example.org/commit> <http://example.org/size> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+		`<http://example.org/commit> <http://example.org/tags> _:b1 .`,
+		`_:b1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "a" .`,
+		`_:b1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:b2 .`,
+		`_:b2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "b" .`,
+		`_:b2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .`,
+		`<http://example.org/commit> <http://example.org/title> "first \"commit\"\n" .`,
+		`<ipfs:` + testHashA + `> <http://example.org/name> "parent" .`,
+		``,
+	}, "\n")
+
+	var buf bytes.Buffer
+	if err := WriteNQuads(&buf, quads); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("N-Quads mismatch.\nGot:\n%s\nExpect:\n%s", buf.String(), expected)
+	}
+
+	parsed, err := ParseNQuads(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, quads) {
+		t.Errorf("parsed quads mismatch.\nGot:    %#v\nExpect: %#v", parsed, quads)
+	}
+}
+
+func TestFromRDF(t *testing.T) {
+	doc := `
+# a commit
+<http://example.org/commit> <http://example.org/parent> <ipfs:` + testHashA + `> .
+<http://example.org/commit> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Commit> .
+<http://example.org/commit> <http://example.org/size> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/commit> <http://example.org/title> "titre"@fr .
+<http://example.org/commit> <http://example.org/tags> _:l0 .
+_:l0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "aé" .
+_:l0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:l1 .
+_:l1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "b" .
+_:l1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+<http://example.org/a> <http://example.org/b> "c" <http://example.org/graph> .
+`
+	quads, err := ParseNQuads(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := FromRDF(quads, &Options{UseNativeTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{
+		ipld.Node{
+			"@id":   "http://example.org/commit",
+			"@type": []interface{}{"http://example.org/Commit"},
+			"http://example.org/parent": []interface{}{
+				ipld.Node{"mlink": testHashA},
+			},
+			"http://example.org/size": []interface{}{
+				ipld.Node{"@value": ipld.Int(42)},
+			},
+			"http://example.org/title": []interface{}{
+				ipld.Node{"@value": "titre", "@language": "fr"},
+			},
+			"http://example.org/tags": []interface{}{
+				ipld.Node{"@list": []interface{}{
+					ipld.Node{"@value": "aé"},
+					ipld.Node{"@value": "b"},
+				}},
+			},
+		},
+		ipld.Node{
+			"@id": "http://example.org/graph",
+			"@graph": []interface{}{
+				ipld.Node{
+					"@id":                  "http://example.org/a",
+					"http://example.org/b": []interface{}{ipld.Node{"@value": "c"}},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("FromRDF mismatch.\nGot:    %#v\nExpect: %#v", res, expected)
+	}
+}
+
+func TestParseNQuadsErrors(t *testing.T) {
+	docs := []string{
+		`<http://a> <http://b> "c"`,
+		`"a" <http://b> <http://c> .`,
+		`<http://a> _:b <http://c> .`,
+		`<http://a> <http://b> "c\q" .`,
+		`<http://a> <http://b> "c" . extra`,
+		`<http://a> <http://b> <http://c`,
+	}
+	for _, doc := range docs {
+		_, err := ParseNQuads(strings.NewReader(doc))
+		if _, ok := err.(*NQuadsError); !ok {
+			t.Errorf("%s: expected a syntax error, got %v", doc, err)
+		}
+	}
+}
+
+func TestParseNQuadsLongLiteral(t *testing.T) {
+	long := strings.Repeat("QUJD", 1<<15) // longer than bufio.MaxScanTokenSize
+	doc := "# comment\r\n<http://a> <http://b> \"" + long + "\" .\n\n<http://a> <http://c> <http://d> ."
+	quads, err := ParseNQuads(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quads) != 2 || quads[0].Object.Value != long || quads[1].Object.Value != "http://d" {
+		t.Errorf("unexpected quads %d", len(quads))
+	}
+}
+
+func TestToRDFAll(t *testing.T) {
+	s := store.NewMemoryStore()
+	child, err := s.Put(ipld.Node{"title": "child"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := s.Put(ipld.Node{
+		"title": "root",
+		"child": ipld.Node{"mlink": child.B58String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quads, err := ToRDFAll(s, root, &Options{ExpandContext: exampleVocab})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootIRI := Term{Kind: IRI, Value: LinkIRIPrefix + root.B58String()}
+	childIRI := Term{Kind: IRI, Value: LinkIRIPrefix + child.B58String()}
+	expected := []Quad{
+		{rootIRI, Term{Kind: IRI, Value: "http://example.org/child"}, childIRI, Term{}},
+		{rootIRI, Term{Kind: IRI, Value: "http://example.org/title"}, Term{Kind: Literal, Value: "root", Datatype: XSDString}, Term{}},
+		{childIRI, Term{Kind: IRI, Value: "http://example.org/title"}, Term{Kind: Literal, Value: "child", Datatype: XSDString}, Term{}},
+	}
+	if !reflect.DeepEqual(quads, expected) {
+		t.Errorf("quads mismatch.\nGot:    %#v\nExpect: %#v", quads, expected)
+	}
+}
+
+func TestJSONLiteral(t *testing.T) {
+	n := ipld.Node{
+		"@context": ipld.Node{"data": ipld.Node{"@id": "http://example.org/data", "@type": "@json"}},
+		"@id":      "http://example.org/a",
+		"data":     ipld.Node{"b": []interface{}{ipld.Int(1), ipld.Float(0.5), "<x>"}, "a": true},
+	}
+
+	quads, err := ToRDF(n, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quads) != 1 {
+		t.Fatalf("expected one quad, got %v", quads)
+	}
+	lit := Term{Kind: Literal, Value: `{"a":true,"b":[1,0.5,"<x>"]}`, Datatype: RDFJSON}
+	if quads[0].Object != lit {
+		t.Errorf("got literal %#v", quads[0].Object)
+	}
+
+	res, err := FromRDF(quads, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{ipld.Node{
+		"@id": "http://example.org/a",
+		"http://example.org/data": []interface{}{ipld.Node{
+			"@value": ipld.Node{"b": []interface{}{ipld.Int(1), ipld.Float(0.5), "<x>"}, "a": true},
+			"@type":  "@json",
+		}},
+	}}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("mismatch:\n%#v\n%#v", res, expected)
+	}
+}
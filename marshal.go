@@ -0,0 +1,631 @@
+package ipld
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Typer is implemented by Go types which set the "@type" of the nodes they
+// are marshalled to. When unmarshalling a node with a "@type" into such a
+// type, the types must match.
+type Typer interface {
+	IPLDType() string
+}
+
+// Contexter is implemented by Go types which set the "@context" of the
+// nodes they are marshalled to.
+type Contexter interface {
+	IPLDContext() interface{}
+}
+
+// ErrNotPointer is returned when Unmarshal is not given a non-nil pointer.
+var ErrNotPointer = errors.New("ipld: Unmarshal requires a non-nil pointer")
+
+// UnmarshalTypeError describes a value which could not be stored in a Go
+// value of a given type.
+type UnmarshalTypeError struct {
+	Path  string       // path of the value in the node
+	Value interface{}  // value that could not be stored
+	Type  reflect.Type // type of the Go value it could not be stored in
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("ipld: cannot unmarshal %T into Go value of type %s at %q", e.Value, e.Type, e.Path)
+}
+
+// TypeMismatchError is returned by Unmarshal when the "@type" of a node
+// does not match the IPLDType of the Go value it is unmarshalled into.
+type TypeMismatchError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("ipld: expected @type %q, got %q at %q", e.Expected, e.Actual, e.Path)
+}
+
+var (
+	nodeType = reflect.TypeOf(Node{})
+	linkType = reflect.TypeOf(Link{})
+	cidType  = reflect.TypeOf(Cid{})
+)
+
+// Marshal returns the Node representing v, which must be a struct, a map
+// with string keys, or a pointer to one of these.
+//
+// Struct fields are mapped to node keys. By default, the key is the field
+// name with a lower case first letter. This can be changed using the
+// "ipld" struct tag, which works like the "json" tag of encoding/json:
+//
+//   // Field is ignored.
+//   Field int `ipld:"-"`
+//
+//   // Field is stored under key "myName".
+//   Field int `ipld:"myName"`
+//
+//   // Field is omitted if it has an empty value.
+//   Field int `ipld:",omitempty"`
+//
+// Fields of embedded structs without a tag are promoted in the outer node.
+// Fields of type Link must hold merkle-links, and slices and maps are
+// converted to []interface{} and Node recursively, except for []byte
+// and Cid which are kept as-is. Maps of a named type like Link keep
+// their type when it has the same underlying type as Node. Numbers are stored as Int, Float or
+// *big.Int, like the codecs decode them.
+//
+// If v implements Typer or Contexter, "@type" and "@context" are set in
+// the resulting node, unless a field already set them.
+func Marshal(v interface{}) (Node, error) {
+	res, err := marshalValue(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	rv := reflect.ValueOf(res)
+	if rv.Kind() != reflect.Map || !rv.Type().ConvertibleTo(nodeType) {
+		return nil, fmt.Errorf("ipld: cannot marshal %T to a Node", v)
+	}
+	return rv.Convert(nodeType).Interface().(Node), nil
+}
+
+func marshalValue(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type() == cidType {
+		return v.Interface(), nil
+	}
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return BigInt(v.Interface().(*big.Int)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return marshalValue(v.Elem())
+
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Uint(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return Float(v.Float()), nil
+	case reflect.String:
+		return v.String(), nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			e, err := marshalValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = e
+		}
+		return s, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("ipld: cannot marshal map with %s keys", v.Type().Key())
+		}
+		n := Node{}
+		for _, k := range v.MapKeys() {
+			e, err := marshalValue(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			n[k.String()] = e
+		}
+		// named map types like Link keep their type, the codecs encode
+		// them differently.
+		if t := v.Type(); t.Name() != "" && t != nodeType && nodeType.ConvertibleTo(t) {
+			return reflect.ValueOf(n).Convert(t).Interface(), nil
+		}
+		return n, nil
+
+	case reflect.Struct:
+		n := Node{}
+		if err := marshalStruct(v, n); err != nil {
+			return nil, err
+		}
+		injectDirectives(v, n)
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("ipld: cannot marshal value of type %s", v.Type())
+}
+
+// injectDirectives sets "@type" and "@context" in n if v declares them.
+func injectDirectives(v reflect.Value, n Node) {
+	if !v.CanInterface() {
+		return // promoted from an unexported embedded struct
+	}
+	i := v.Interface()
+	if v.CanAddr() {
+		i = v.Addr().Interface()
+	}
+
+	if t, ok := i.(Typer); ok {
+		if _, set := n[TypeKey]; !set {
+			n[TypeKey] = t.IPLDType()
+		}
+	}
+	if c, ok := i.(Contexter); ok {
+		if _, set := n[CtxKey]; !set {
+			n[CtxKey] = c.IPLDContext()
+		}
+	}
+}
+
+func marshalStruct(v reflect.Value, n Node) error {
+	for _, f := range structFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok {
+			continue // nil embedded pointer
+		}
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		e, err := marshalValue(fv)
+		if err != nil {
+			return err
+		}
+		if f.typ == linkType && e != nil && !IsLink(e) {
+			return fmt.Errorf("ipld: field %s is not a valid link", f.name)
+		}
+		n[f.name] = e
+	}
+	return nil
+}
+
+// Unmarshal stores the values of n in the value pointed to by v. It is the
+// inverse of Marshal, and accepts the numeric types produced by the
+// codecs, converting them to the Go field types when they fit. Cid fields
+// also accept string representations. Keys of n which do not correspond to
+// any field are ignored.
+//
+// Once decoded, v is checked with Validate, and a *ValidationError is
+// returned if any Validator it contains reports failures.
+func Unmarshal(n Node, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrNotPointer
+	}
+	if err := unmarshalValue(n, rv.Elem(), ""); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+func unmarshalValue(src interface{}, dst reflect.Value, p string) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	typeErr := &UnmarshalTypeError{Path: p, Value: src, Type: dst.Type()}
+
+	if dst.Type() == cidType {
+		switch c := src.(type) {
+		case Cid:
+			dst.Set(reflect.ValueOf(c))
+		case string:
+			cid, err := ParseCid(c)
+			if err != nil {
+				return typeErr
+			}
+			dst.Set(reflect.ValueOf(cid))
+		default:
+			return typeErr
+		}
+		return nil
+	}
+
+	if dst.Type() == bigIntType.Elem() {
+		b, ok := toBigInt(src)
+		if !ok {
+			return typeErr
+		}
+		dst.Addr().Interface().(*big.Int).Set(b)
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return unmarshalValue(src, dst.Elem(), p)
+
+	case reflect.Interface:
+		sv := reflect.ValueOf(src)
+		if !sv.Type().AssignableTo(dst.Type()) {
+			return typeErr
+		}
+		dst.Set(sv)
+		return nil
+
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return typeErr
+		}
+		dst.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt64(src)
+		if !ok || dst.OverflowInt(i) {
+			return typeErr
+		}
+		dst.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, ok := toUint64(src)
+		if !ok || dst.OverflowUint(u) {
+			return typeErr
+		}
+		dst.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat64(src)
+		if !ok {
+			return typeErr
+		}
+		dst.SetFloat(f)
+
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return typeErr
+		}
+		dst.SetString(s)
+
+	case reflect.Slice, reflect.Array:
+		return unmarshalSequence(src, dst, p, typeErr)
+
+	case reflect.Map:
+		sn, ok := toNode(src)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return typeErr
+		}
+		if dst.Type() == linkType && !IsLink(sn) {
+			return typeErr
+		}
+		m := reflect.MakeMap(dst.Type())
+		for k, e := range sn {
+			ev := reflect.New(dst.Type().Elem()).Elem()
+			if err := unmarshalValue(e, ev, path.Join(p, k)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), ev)
+		}
+		dst.Set(m)
+
+	case reflect.Struct:
+		sn, ok := toNode(src)
+		if !ok {
+			return typeErr
+		}
+		return unmarshalStruct(sn, dst, p)
+
+	default:
+		return typeErr
+	}
+	return nil
+}
+
+func unmarshalSequence(src interface{}, dst reflect.Value, p string, typeErr error) error {
+	if dst.Type().Elem().Kind() == reflect.Uint8 {
+		b, ok := src.([]byte)
+		if !ok {
+			return typeErr
+		}
+		if dst.Kind() == reflect.Array {
+			if len(b) != dst.Len() {
+				return typeErr
+			}
+			reflect.Copy(dst, reflect.ValueOf(b))
+		} else {
+			dst.SetBytes(append([]byte(nil), b...))
+		}
+		return nil
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array {
+		return typeErr
+	}
+
+	if dst.Kind() == reflect.Array {
+		if sv.Len() != dst.Len() {
+			return typeErr
+		}
+	} else {
+		dst.Set(reflect.MakeSlice(dst.Type(), sv.Len(), sv.Len()))
+	}
+	for i := 0; i < sv.Len(); i++ {
+		err := unmarshalValue(sv.Index(i).Interface(), dst.Index(i), path.Join(p, strconv.Itoa(i)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalStruct(n Node, dst reflect.Value, p string) error {
+	if dst.CanAddr() {
+		if t, ok := dst.Addr().Interface().(Typer); ok {
+			if actual, ok := n[TypeKey].(string); ok && actual != t.IPLDType() {
+				return &TypeMismatchError{Path: p, Expected: t.IPLDType(), Actual: actual}
+			}
+		}
+	}
+
+	for _, f := range structFields(dst.Type()) {
+		src, ok := n[f.name]
+		if !ok {
+			continue
+		}
+		fv, ok := fieldByIndex(dst, f.index, true)
+		if !ok {
+			continue // embedded pointer to an unexported struct
+		}
+		if err := unmarshalValue(src, fv, path.Join(p, f.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field describes how a struct field maps to a node key.
+type field struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// structFields returns the fields of struct type t, including promoted
+// fields of embedded structs. Fields of the outer struct hide fields of
+// the same name in embedded structs.
+func structFields(t reflect.Type) []field {
+	var fields []field
+	seen := map[string]bool{}
+
+	var visit func(t reflect.Type, index []int)
+	visit = func(t reflect.Type, index []int) {
+		var embedded []reflect.StructField
+
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("ipld")
+			if tag == "-" {
+				continue
+			}
+			name, opts := parseTag(tag)
+
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				embedded = append(embedded, sf)
+				continue
+			}
+			if sf.PkgPath != "" { // unexported
+				continue
+			}
+
+			if name == "" {
+				name = defaultFieldName(sf.Name)
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			fields = append(fields, field{
+				name:      name,
+				index:     append(append([]int{}, index...), i),
+				typ:       sf.Type,
+				omitEmpty: opts == "omitempty",
+			})
+		}
+
+		for _, sf := range embedded {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			visit(ft, append(append([]int{}, index...), sf.Index[0]))
+		}
+	}
+
+	visit(t, nil)
+	return fields
+}
+
+func parseTag(tag string) (name, opts string) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+// defaultFieldName lower cases the first letter of a field name.
+func defaultFieldName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+// fieldByIndex returns the field of v at index, going through embedded
+// pointers. If alloc is true, nil embedded pointers are allocated when
+// possible. ok is false if a nil embedded pointer is left on the way.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (f reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// toNode returns src as a Node if it is a map with string keys.
+func toNode(src interface{}) (Node, bool) {
+	switch s := src.(type) {
+	case Node:
+		return s, true
+	case Link:
+		return Node(s), true
+	case map[string]interface{}:
+		return Node(s), true
+	}
+	return nil, false
+}
+
+func toInt64(src interface{}) (int64, bool) {
+	if b, ok := src.(*big.Int); ok {
+		i, ok := BigInt(b).(Int)
+		return int64(i), ok
+	}
+	v := reflect.ValueOf(src)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		return int64(u), u <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return int64(f), f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64
+	}
+	return 0, false
+}
+
+func toUint64(src interface{}) (uint64, bool) {
+	if b, ok := src.(*big.Int); ok {
+		return b.Uint64(), b.Sign() >= 0 && b.BitLen() <= 64
+	}
+	v := reflect.ValueOf(src)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		return uint64(i), i >= 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return uint64(f), f == math.Trunc(f) && f >= 0 && f < math.MaxUint64
+	}
+	return 0, false
+}
+
+func toFloat64(src interface{}) (float64, bool) {
+	if b, ok := src.(*big.Int); ok {
+		f, _ := new(big.Float).SetInt(b).Float64()
+		return f, true
+	}
+	v := reflect.ValueOf(src)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func toBigInt(src interface{}) (*big.Int, bool) {
+	if b, ok := src.(*big.Int); ok {
+		return b, true
+	}
+	v := reflect.ValueOf(src)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		b, _ := big.NewFloat(f).Int(nil)
+		return b, true
+	}
+	return nil, false
+}
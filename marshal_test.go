@@ -0,0 +1,143 @@
+package ipld
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+type Signer struct {
+	Name  string
+	Email string `ipld:"mail,omitempty"`
+}
+
+type testMeta struct {
+	Date    int64
+	Comment string `ipld:"comment"`
+}
+
+type testCommit struct {
+	testMeta
+	*Signer
+
+	Parents []Link
+	Author  Link
+	Object  Link `ipld:"tree"`
+	Size    uint32
+	Extra   Node `ipld:",omitempty"`
+	Ignored bool `ipld:"-"`
+	private int
+}
+
+func (c *testCommit) IPLDType() string { return "commit" }
+func (c *testCommit) IPLDContext() interface{} {
+	return "/ipfs/QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo/commit"
+}
+
+var (
+	testLinkA = Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPa"}
+	testLinkB = Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPb"}
+)
+
+func TestMarshal(t *testing.T) {
+	c := &testCommit{
+		testMeta: testMeta{Date: -42, Comment: "initial"},
+		Signer:   &Signer{Name: "me"},
+		Parents:  []Link{testLinkA, testLinkB},
+		Author:   testLinkA,
+		Object:   testLinkB,
+		Size:     12,
+		Ignored:  true,
+	}
+
+	n, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Node{
+		"@type":    "commit",
+		"@context": "/ipfs/QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo/commit",
+		"date":     Int(-42),
+		"comment":  "initial",
+		"name":     "me",
+		"parents":  []interface{}{testLinkA, testLinkB},
+		"author":   testLinkA,
+		"tree":     testLinkB,
+		"size":     Int(12),
+	}
+	if !reflect.DeepEqual(n, expected) {
+		t.Errorf("marshal mismatch.\nGot:    %#v\nExpect: %#v", n, expected)
+	}
+
+	if len(n.Links()) != 4 {
+		t.Errorf("expected 4 links, got %#v", n.Links())
+	}
+
+	var c2 testCommit
+	if err := Unmarshal(n, &c2); err != nil {
+		t.Fatal(err)
+	}
+	c.Ignored = false
+	if !reflect.DeepEqual(c, &c2) {
+		t.Errorf("unmarshal mismatch.\nGot:    %#v\nExpect: %#v", &c2, c)
+	}
+
+	if _, err := Marshal(&testCommit{Author: Link{"foo": "bar"}}); err == nil {
+		t.Error("expected error marshalling an invalid link")
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	// values as decoded by the codecs
+	n := Node{
+		"@type":   "commit",
+		"date":    float64(1444000000),
+		"size":    uint64(12),
+		"parents": []interface{}{Node(testLinkA)},
+		"mail":    "me@example.com",
+		"unknown": "ignored",
+	}
+
+	var c testCommit
+	if err := Unmarshal(n, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Date != 1444000000 || c.Size != 12 || len(c.Parents) != 1 || c.Email != "me@example.com" {
+		t.Errorf("unexpected result: %#v", c)
+	}
+
+	errorCases := []struct {
+		n    Node
+		path string
+	}{
+		{Node{"size": int64(-1)}, "size"},
+		{Node{"size": uint64(1 << 40)}, "size"},
+		{Node{"date": 1.5}, "date"},
+		{Node{"date": Float(1.5)}, "date"},
+		{Node{"date": new(big.Int).Lsh(big.NewInt(1), 64)}, "date"},
+		{Node{"comment": 1}, "comment"},
+		{Node{"author": Node{"foo": "bar"}}, "author"},
+		{Node{"parents": []interface{}{Node(testLinkA), "foo"}}, "parents/1"},
+	}
+	for _, ec := range errorCases {
+		var c testCommit
+		err := Unmarshal(ec.n, &c)
+		terr, ok := err.(*UnmarshalTypeError)
+		if !ok {
+			t.Errorf("%#v: expected UnmarshalTypeError, got %v", ec.n, err)
+		} else if terr.Path != ec.path {
+			t.Errorf("%#v: expected error at %s, got %s", ec.n, ec.path, terr.Path)
+		}
+	}
+
+	if err := Unmarshal(Node{"@type": "tree"}, &c); err == nil {
+		t.Error("expected @type mismatch error")
+	} else if _, ok := err.(*TypeMismatchError); !ok {
+		t.Errorf("expected TypeMismatchError, got %v", err)
+	}
+
+	if err := Unmarshal(n, c); err != ErrNotPointer {
+		t.Errorf("expected ErrNotPointer, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package ipld
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	ErrEmptyPath    = errors.New("empty path")
+	ErrNotContainer = errors.New("value is not a map or a list")
+	ErrInvalidIndex = errors.New("invalid list index")
+)
+
+// PathError records an error and the path at which it happened.
+type PathError struct {
+	Path Path
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return "ipld: at " + pathOrRoot(e.Path) + ": " + e.Err.Error()
+}
+
+func pathOrRoot(p Path) string {
+	if len(p) == 0 {
+		return "/"
+	}
+	return p.String()
+}
+
+// SetPath sets the descendant of root at npath to value, and returns the
+// root. Map keys are escaped like GetPathCmp does. Missing intermediate
+// values are created as Nodes, and lists are grown with nil elements up
+// to the index being set.
+//
+// SetPath modifies root and the values along npath in place, except
+// maps and lists other than Node, Link, map[string]interface{} and
+// []interface{} (see AsMap and AsList), which are replaced by a modified
+// copy. PersistentNodes along npath are never modified, they are replaced
+// by updated versions (see PersistentNode.SetPath). If root is nil, a new
+// Node is returned.
+//
+// SetPath fails with a *PathError if a value along npath is not a map or
+// a list, or if a list is indexed with a component which is not a
+// non-negative integer.
+func SetPath(root Node, npath Path, value interface{}) (Node, error) {
+	return setRoot(root, npath, value, false)
+}
+
+// SetPathCopy is just like SetPath, but leaves root untouched: the maps
+// and lists along npath are copied, and a new root is returned. The
+// values which are not on npath are shared with root.
+func SetPathCopy(root Node, npath Path, value interface{}) (Node, error) {
+	return setRoot(root, npath, value, true)
+}
+
+// DeletePath removes the descendant of root at npath, and returns the
+// root. Map keys are escaped like GetPathCmp does. List elements are
+// removed, so the following elements are shifted. Deleting a path which
+// does not exist does nothing.
+//
+// Like SetPath, DeletePath modifies root in place.
+func DeletePath(root Node, npath Path) (Node, error) {
+	return deleteRoot(root, npath, false)
+}
+
+// DeletePathCopy is just like DeletePath, but leaves root untouched like
+// SetPathCopy. If npath does not exist, root itself is returned.
+func DeletePathCopy(root Node, npath Path) (Node, error) {
+	return deleteRoot(root, npath, true)
+}
+
+func setRoot(root Node, npath Path, value interface{}, cow bool) (Node, error) {
+	if len(npath) == 0 {
+		return nil, ErrEmptyPath
+	}
+	res, err := setPath(root, npath, 0, value, cow)
+	if err != nil {
+		return nil, err
+	}
+	return res.(Node), nil
+}
+
+func deleteRoot(root Node, npath Path, cow bool) (Node, error) {
+	if len(npath) == 0 {
+		return nil, ErrEmptyPath
+	}
+	if res, changed := deletePath(root, npath, 0, cow); changed {
+		return res.(Node), nil
+	}
+	return root, nil
+}
+
+// setPath sets npath[i:] below curr, and returns the value replacing curr
+// in its parent.
+func setPath(curr interface{}, npath Path, i int, value interface{}, cow bool) (interface{}, error) {
+	if i == len(npath) {
+		return value, nil
+	}
+
+	if curr == nil {
+		curr = Node{}
+	}
+	if p, ok := curr.(PersistentNode); ok {
+		// the values of a PersistentNode are not modified in place, and
+		// missing maps below it are PersistentNodes as well.
+		k := EscapePathComponent(npath[i])
+		old, exists := p.Lookup(k)
+		if !exists || old == nil {
+			old = PersistentNode{}
+		}
+		v, err := setPath(old, npath, i+1, value, true)
+		if err != nil {
+			return nil, err
+		}
+		return p.Set(k, v), nil
+
+	} else if n, ok := AsMap(curr); ok {
+		n = mutableMap(curr, n, cow)
+		k := EscapePathComponent(npath[i])
+		v, err := setPath(n[k], npath, i+1, value, cow)
+		if err != nil {
+			return nil, err
+		}
+		n[k] = v
+		return sameMapType(curr, n), nil
+
+	} else if s, ok := AsList(curr); ok {
+		idx, err := strconv.Atoi(npath[i])
+		if err != nil || idx < 0 {
+			return nil, &PathError{npath[:i+1], ErrInvalidIndex}
+		}
+		s = mutableList(curr, s, cow, idx+1)
+		v, err := setPath(s[idx], npath, i+1, value, cow)
+		if err != nil {
+			return nil, err
+		}
+		s[idx] = v
+		return s, nil
+	}
+
+	return nil, &PathError{npath[:i], ErrNotContainer}
+}
+
+// deletePath removes npath[i:] below curr, and returns the value replacing
+// curr in its parent, and whether anything was removed.
+func deletePath(curr interface{}, npath Path, i int, cow bool) (interface{}, bool) {
+	last := i == len(npath)-1
+
+	if p, ok := curr.(PersistentNode); ok {
+		k := EscapePathComponent(npath[i])
+		v, exists := p.Lookup(k)
+		if !exists {
+			return curr, false
+		}
+		if last {
+			return p.Delete(k), true
+		}
+		if v, exists = deletePath(v, npath, i+1, true); !exists {
+			return curr, false
+		}
+		return p.Set(k, v), true
+
+	} else if n, ok := AsMap(curr); ok {
+		k := EscapePathComponent(npath[i])
+		v, exists := n[k]
+		if !exists {
+			return curr, false
+		}
+		if !last {
+			if v, exists = deletePath(v, npath, i+1, cow); !exists {
+				return curr, false
+			}
+		}
+
+		n = mutableMap(curr, n, cow)
+		if last {
+			delete(n, k)
+		} else {
+			n[k] = v
+		}
+		return sameMapType(curr, n), true
+
+	} else if s, ok := AsList(curr); ok {
+		idx, err := strconv.Atoi(npath[i])
+		if err != nil || idx < 0 || idx >= len(s) {
+			return curr, false
+		}
+		v := s[idx]
+		if !last {
+			if v, ok = deletePath(v, npath, i+1, cow); !ok {
+				return curr, false
+			}
+		}
+
+		s = mutableList(curr, s, cow, 0)
+		if last {
+			s = append(s[:idx], s[idx+1:]...)
+		} else {
+			s[idx] = v
+		}
+		return s, true
+	}
+
+	return curr, false
+}
+
+// mutableMap returns a map holding the entries of n, the map form of curr,
+// which may be modified: n itself, or a copy of it in copy-on-write mode.
+// AsMap already returns a copy of typed maps.
+func mutableMap(curr interface{}, n Node, cow bool) Node {
+	switch curr.(type) {
+	case Node, Link, map[string]interface{}:
+		if cow || n == nil {
+			c := make(Node, len(n)+1)
+			for k, v := range n {
+				c[k] = v
+			}
+			return c
+		}
+	}
+	return n
+}
+
+// sameMapType returns n with the type of curr if it is a Link or a
+// map[string]interface{}, and as a Node otherwise.
+func sameMapType(curr interface{}, n Node) interface{} {
+	switch curr.(type) {
+	case Link:
+		return Link(n)
+	case map[string]interface{}:
+		return map[string]interface{}(n)
+	}
+	return n
+}
+
+// mutableList is like mutableMap for lists. The list returned holds at
+// least size elements, padded with nil.
+func mutableList(curr interface{}, s []interface{}, cow bool, size int) []interface{} {
+	if _, ok := curr.([]interface{}); ok && cow {
+		c := make([]interface{}, len(s), len(s)+1)
+		copy(c, s)
+		s = c
+	}
+	if len(s) < size {
+		s = append(s, make([]interface{}, size-len(s))...)
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package ipld
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetPath(t *testing.T) {
+	n := Node{
+		"a":    Node{"b": 1},
+		"list": []interface{}{"x"},
+		"ints": []int{1, 2},
+	}
+
+	cases := []struct {
+		path  string
+		value interface{}
+	}{
+		{"a/b", 2},
+		{"a/c/d", "new"},
+		{"list/0", "y"},
+		{"list/2/e", true},
+		{"ints/1", "two"},
+		{`@type`, "escaped"},
+		{`a\/b`, "slash"},
+	}
+	for _, c := range cases {
+		res, err := SetPath(n, ParsePath(c.path), c.value)
+		if err != nil {
+			t.Fatalf("%s: %s", c.path, err)
+		}
+		if !reflect.DeepEqual(res, n) {
+			t.Errorf("%s: expected the root to be modified in place", c.path)
+		}
+		if v := GetPath(n, c.path); !reflect.DeepEqual(v, c.value) {
+			t.Errorf("%s: expected %#v, got %#v", c.path, c.value, v)
+		}
+	}
+
+	expected := Node{
+		"a":      Node{"b": 2, "c": Node{"d": "new"}},
+		"list":   []interface{}{"y", nil, Node{"e": true}},
+		"ints":   []interface{}{1, "two"},
+		`\@type`: "escaped",
+		"a/b":    "slash",
+	}
+	if !reflect.DeepEqual(n, expected) {
+		t.Errorf("expected %#v, got %#v", expected, n)
+	}
+
+	res, err := SetPath(nil, Path{"x", "y"}, 1)
+	if err != nil || !reflect.DeepEqual(res, Node{"x": Node{"y": 1}}) {
+		t.Errorf("unexpected result %#v, %v", res, err)
+	}
+
+	errs := []struct {
+		path Path
+		err  error
+	}{
+		{Path{"a", "b", "c"}, ErrNotContainer},
+		{Path{"list", "foo"}, ErrInvalidIndex},
+		{Path{"list", "-1"}, ErrInvalidIndex},
+	}
+	for _, c := range errs {
+		_, err := SetPath(n, c.path, 1)
+		if perr, ok := err.(*PathError); !ok || perr.Err != c.err {
+			t.Errorf("%v: expected %v, got %v", c.path, c.err, err)
+		}
+	}
+	if _, err := SetPath(n, nil, 1); err != ErrEmptyPath {
+		t.Errorf("expected ErrEmptyPath, got %v", err)
+	}
+}
+
+func TestSetPathCopy(t *testing.T) {
+	shared := Node{"s": 1}
+	link := Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"}
+	n := Node{
+		"a":      Node{"b": []interface{}{1, 2}},
+		"shared": shared,
+		"link":   link,
+	}
+	orig := Node{
+		"a":      Node{"b": []interface{}{1, 2}},
+		"shared": Node{"s": 1},
+		"link":   Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"},
+	}
+
+	res, err := SetPathCopy(n, ParsePath("a/b/1"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = SetPathCopy(res, ParsePath("link/name"), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, orig) {
+		t.Errorf("original modified: %#v", n)
+	}
+	if v := GetPath(res, "a/b/1"); v != 3 {
+		t.Errorf("unexpected value %#v", v)
+	}
+	if l, ok := res["link"].(Link); !ok || l["name"] != "foo" {
+		t.Errorf("expected a named link, got %#v", res["link"])
+	}
+	if reflect.ValueOf(res["shared"]).Pointer() != reflect.ValueOf(shared).Pointer() {
+		t.Errorf("values outside the path are not shared")
+	}
+}
+
+func TestDeletePath(t *testing.T) {
+	n := Node{
+		"a":    Node{"b": 1, "c": 2},
+		"list": []interface{}{"x", Node{"d": 1}, "z"},
+	}
+	orig := Node{
+		"a":    Node{"b": 1, "c": 2},
+		"list": []interface{}{"x", Node{"d": 1}, "z"},
+	}
+
+	res, err := DeletePathCopy(n, ParsePath("a/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = DeletePathCopy(res, ParsePath("list/1/d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = DeletePathCopy(res, ParsePath("list/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Node{
+		"a":    Node{"c": 2},
+		"list": []interface{}{Node{}, "z"},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %#v, got %#v", expected, res)
+	}
+	if !reflect.DeepEqual(n, orig) {
+		t.Errorf("original modified: %#v", n)
+	}
+
+	for _, p := range []string{"missing", "a/b/c", "list/3", "list/foo"} {
+		res, err := DeletePathCopy(n, ParsePath(p))
+		if err != nil || reflect.ValueOf(res).Pointer() != reflect.ValueOf(n).Pointer() {
+			t.Errorf("%s: expected the root to be returned, got %#v, %v", p, res, err)
+		}
+	}
+
+	if _, err := DeletePath(n, ParsePath("list/1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeletePath(n, ParsePath("a")); err != nil {
+		t.Fatal(err)
+	}
+	expected = Node{"list": []interface{}{"x", "z"}}
+	if !reflect.DeepEqual(n, expected) {
+		t.Errorf("expected %#v, got %#v", expected, n)
+	}
+}
@@ -0,0 +1,95 @@
+package ipld
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Numbers in the IPLD data model are either integers or floats, and the
+// two kinds are kept apart by the codecs, so a node keeps its bytes and
+// hash when converted from one serialization to another:
+//
+//   - integers are decoded as Int, or *big.Int when they do not fit in 64
+//     bits signed
+//   - floats are decoded as Float, even when their value is integral
+//
+// Native Go numbers are accepted when encoding, as the integer or float
+// kind of their type.
+
+// Int is an integer of the IPLD data model.
+type Int int64
+
+// Float is a floating point number of the IPLD data model.
+type Float float64
+
+var ErrInvalidFloat = errors.New("float value is NaN or infinite")
+
+var bigIntType = reflect.TypeOf((*big.Int)(nil))
+
+var (
+	minInt = big.NewInt(math.MinInt64)
+	maxInt = big.NewInt(math.MaxInt64)
+)
+
+// MarshalJSON encodes f with a fraction or an exponent, so it is not read
+// back as an integer.
+func (f Float) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, ErrInvalidFloat
+	}
+
+	var s string
+	if abs := math.Abs(v); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		s = strconv.FormatFloat(v, 'e', -1, 64)
+	} else {
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return []byte(s), nil
+}
+
+// BigInt returns i as an Int if it fits, or i itself.
+func BigInt(i *big.Int) interface{} {
+	if i.Cmp(minInt) >= 0 && i.Cmp(maxInt) <= 0 {
+		return Int(i.Int64())
+	}
+	return i
+}
+
+// Uint returns u as an Int if it fits, or as a *big.Int.
+func Uint(u uint64) interface{} {
+	if u <= math.MaxInt64 {
+		return Int(u)
+	}
+	return new(big.Int).SetUint64(u)
+}
+
+// Number returns the data model representation of the number v, which
+// may be any Go integer or float type, or a *big.Int. ok is false if v is
+// not a number.
+func Number(v interface{}) (n interface{}, ok bool) {
+	if b, isBig := v.(*big.Int); isBig {
+		if b == nil {
+			return nil, false
+		}
+		return BigInt(b), true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Uint(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return Float(rv.Float()), true
+	}
+	return nil, false
+}
@@ -0,0 +1,66 @@
+package ipld
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestFloatMarshalJSON(t *testing.T) {
+	cases := []struct {
+		f        Float
+		expected string
+	}{
+		{0, "0.0"},
+		{1, "1.0"},
+		{-2.5, "-2.5"},
+		{1e21, "1e+21"},
+		{1e-7, "1e-07"},
+		{123456789, "123456789.0"},
+	}
+	for _, c := range cases {
+		b, err := c.f.MarshalJSON()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(b) != c.expected {
+			t.Errorf("%v: expected %s, got %s", float64(c.f), c.expected, b)
+		}
+	}
+
+	if _, err := Float(math.NaN()).MarshalJSON(); err != ErrInvalidFloat {
+		t.Errorf("expected %v, got %v", ErrInvalidFloat, err)
+	}
+}
+
+func TestNumber(t *testing.T) {
+	huge := new(big.Int).Lsh(big.NewInt(1), 64)
+	cases := []struct {
+		v        interface{}
+		expected interface{}
+	}{
+		{42, Int(42)},
+		{int8(-3), Int(-3)},
+		{uint64(math.MaxInt64), Int(math.MaxInt64)},
+		{uint64(math.MaxUint64), new(big.Int).SetUint64(math.MaxUint64)},
+		{float32(1), Float(1)},
+		{2.5, Float(2.5)},
+		{big.NewInt(-7), Int(-7)},
+		{huge, huge},
+	}
+	for _, c := range cases {
+		n, ok := Number(c.v)
+		if !ok || !reflect.DeepEqual(n, c.expected) {
+			t.Errorf("%#v: expected %#v, got %#v", c.v, c.expected, n)
+		}
+	}
+
+	if _, ok := Number("1"); ok {
+		t.Error("a string is not a number")
+	}
+	if _, ok := Number((*big.Int)(nil)); ok {
+		t.Error("a nil *big.Int is not a number")
+	}
+}
@@ -0,0 +1,104 @@
+package ipld
+
+import (
+	"strings"
+)
+
+const pathSep = "/"
+
+// Path is a path within an IPLD data structure, from a node to one of its
+// descendants. Each component is either a map key, unescaped (see
+// UnescapePathComponent), or the decimal offset of a list element. User
+// keys starting with "@" are stored escaped in the data, so the component
+// "@foo" addresses the key stored as "\@foo", and directives such as
+// "@type" are not part of these paths; GetRawPath addresses the keys as
+// stored, directives included.
+//
+// The string form of a path joins its components with "/". Within a
+// component, "\" is escaped as "\\" and "/" as "\/", so that any key can be
+// addressed and String and ParsePath are inverse of each other:
+//
+//   Path{"foo", "a/b", `c\d`, "@e", "0"}   is written   foo/a\/b/c\\d/@e/0
+//
+// A Path may be shared: the methods never modify the receiver.
+type Path []string
+
+// ParsePath parses the string form of a path. Empty components are
+// ignored, so the leading "/" is optional. "\\" is read as "\" and "\/" as
+// "/" within a component; other backslashes are kept as-is.
+func ParsePath(s string) Path {
+	var p Path
+	var comp []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '\\' || s[i+1] == '/'):
+			i++
+			comp = append(comp, s[i])
+		case c == '/':
+			if len(comp) > 0 {
+				p = append(p, string(comp))
+				comp = comp[:0]
+			}
+		default:
+			comp = append(comp, c)
+		}
+	}
+	if len(comp) > 0 {
+		p = append(p, string(comp))
+	}
+	return p
+}
+
+// String returns the string form of p, which ParsePath reads back. The
+// empty path is written "".
+func (p Path) String() string {
+	comps := make([]string, len(p))
+	for i, c := range p {
+		comps[i] = escapePathString(c)
+	}
+	return strings.Join(comps, pathSep)
+}
+
+// Append returns a new path made of p followed by comps. The result never
+// shares its array with p, so paths returned by Append may be kept.
+func (p Path) Append(comps ...string) Path {
+	return append(p[:len(p):len(p)], comps...)
+}
+
+// Join returns a new path made of p followed by the components of q.
+func (p Path) Join(q Path) Path {
+	return p.Append(q...)
+}
+
+// Parent returns the path of the parent of p. The parent of the empty
+// path is the empty path.
+func (p Path) Parent() Path {
+	if len(p) == 0 {
+		return p
+	}
+	return p[: len(p)-1 : len(p)-1]
+}
+
+// Escape path component. The special characters ("@" and "\") are escaped to
+// allow mixing the path component with directives (starting with "@") in IPLD
+// data structure.
+func EscapePathComponent(comp string) string {
+	comp = strings.Replace(comp, "\\", "\\\\", -1)
+	comp = strings.Replace(comp, "@", "\\@", -1)
+	return comp
+}
+
+// Unescape path component from the IPLD data structure. Special characters are
+// unescaped. See also EscapePathComponent function.
+func UnescapePathComponent(comp string) string {
+	comp = strings.Replace(comp, "\\@", "@", -1)
+	comp = strings.Replace(comp, "\\\\", "\\", -1)
+	return comp
+}
+
+// escapePathString escapes a path component for the string form of a path.
+func escapePathString(comp string) string {
+	comp = strings.Replace(comp, "\\", "\\\\", -1)
+	comp = strings.Replace(comp, pathSep, "\\"+pathSep, -1)
+	return comp
+}
@@ -0,0 +1,161 @@
+package ipld
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		s string
+		p Path
+	}{
+		{"", nil},
+		{"/", nil},
+		{"foo", Path{"foo"}},
+		{"/foo/bar/", Path{"foo", "bar"}},
+		{"foo//0", Path{"foo", "0"}},
+		{`a\/b/c`, Path{"a/b", "c"}},
+		{`a\\/b`, Path{`a\`, "b"}},
+		{`a\\\/b`, Path{`a\/b`}},
+		{`bar2/\@foo`, Path{"bar2", `\@foo`}},
+		{"bar2/@foo", Path{"bar2", "@foo"}},
+		{`a\x`, Path{`a\x`}},
+		{`a\`, Path{`a\`}},
+	}
+	for _, c := range cases {
+		if p := ParsePath(c.s); !reflect.DeepEqual(p, c.p) {
+			t.Errorf("%q: expected %#v, got %#v", c.s, c.p, p)
+		}
+	}
+}
+
+func TestPathString(t *testing.T) {
+	cases := []struct {
+		p Path
+		s string
+	}{
+		{nil, ""},
+		{Path{"foo", "0"}, "foo/0"},
+		{Path{"a/b", `c\d`, "@e", `\@f`}, `a\/b/c\\d/@e/\\@f`},
+		{Path{`a\`, "b"}, `a\\/b`},
+		{Path{`a\/b`}, `a\\\/b`},
+	}
+	for _, c := range cases {
+		if s := c.p.String(); s != c.s {
+			t.Errorf("%#v: expected %q, got %q", c.p, c.s, s)
+		}
+		if p := ParsePath(c.p.String()); !reflect.DeepEqual(p, c.p) {
+			t.Errorf("%#v: parsed back as %#v", c.p, p)
+		}
+	}
+}
+
+func TestPathAppend(t *testing.T) {
+	p := ParsePath("a/b")
+	q := p.Append("c")
+	r := p.Append("d")
+	if q.String() != "a/b/c" || r.String() != "a/b/d" {
+		t.Errorf("appended paths share their array: %v, %v", q, r)
+	}
+	if j := p.Join(Path{"x", "y"}); j.String() != "a/b/x/y" {
+		t.Errorf("unexpected join %v", j)
+	}
+
+	parent := q.Parent()
+	if parent.String() != "a/b" {
+		t.Errorf("unexpected parent %v", parent)
+	}
+	if s := parent.Append("e"); q.String() != "a/b/c" || s.String() != "a/b/e" {
+		t.Errorf("parent shares its array: %v, %v", q, s)
+	}
+	if p := (Path{}).Parent(); len(p) != 0 {
+		t.Errorf("unexpected parent of the empty path %v", p)
+	}
+}
+
+func TestPathRoundtrip(t *testing.T) {
+	keys := []string{`a\`, `a\/b`, `a\\/b`, `a\\`, `\`, "a/b", `a/b\`, "@x", `\@x`, `\\@x`}
+	seen := map[string]string{}
+	for _, k := range keys {
+		p := Path{k, "c"}
+		s := p.String()
+		if other, ok := seen[s]; ok {
+			t.Errorf("%q and %q are both written %q", k, other, s)
+		}
+		seen[s] = k
+
+		if q := ParsePath(s); !reflect.DeepEqual(q, p) {
+			t.Errorf("%q: %q parsed back as %#v", k, s, q)
+		}
+		n := Node{EscapePathComponent(k): Node{"c": k}}
+		if v := GetPath(n, s); v != k {
+			t.Errorf("%q: unexpected value %#v at %q", k, v, s)
+		}
+	}
+}
+
+func TestDirectivePaths(t *testing.T) {
+	n := Node{
+		"@type":  "directive",
+		`\@type`: Node{"a": 1},
+	}
+
+	if v := GetPath(n, "@type/a"); v != 1 {
+		t.Errorf("unexpected value %#v", v)
+	}
+	if v := GetRawPath(n, Path{"@type"}); v != "directive" {
+		t.Errorf("unexpected directive %#v", v)
+	}
+	if v := GetRawPath(n, Path{`\@type`, "a"}); v != 1 {
+		t.Errorf("unexpected value %#v", v)
+	}
+	if v := GetRawPath(n, ParsePath(Path{`\@type`}.String())); !reflect.DeepEqual(v, n[`\@type`]) {
+		t.Errorf("unexpected value %#v", v)
+	}
+
+	var paths []string
+	err := WalkOrdered(n, nil, func(root, curr Node, path Path, err error) error {
+		paths = append(paths, path.String())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"", "@type"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+	if v := GetPath(n, paths[1]); !reflect.DeepEqual(v, n[`\@type`]) {
+		t.Errorf("unexpected value %#v", v)
+	}
+}
+
+func TestSlashKeys(t *testing.T) {
+	n := Node{
+		"a/b": Node{"c": Node{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"}},
+	}
+
+	var paths []Path
+	err := WalkOrdered(n, nil, func(root, curr Node, path Path, err error) error {
+		paths = append(paths, path)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Path{nil, {"a/b"}, {"a/b", "c"}}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %#v, got %#v", expected, paths)
+	}
+
+	if _, ok := Links(n)[`a\/b/c`]; !ok {
+		t.Errorf("link not found in %v", Links(n))
+	}
+	if v := GetPath(n, `/a\/b/c/mlink`); v != "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo" {
+		t.Errorf("unexpected value %#v", v)
+	}
+	if v := GetPathCmp(n, paths[2]); !reflect.DeepEqual(v, n["a/b"].(Node)["c"]) {
+		t.Errorf("unexpected value %#v", v)
+	}
+}
@@ -0,0 +1,151 @@
+package ipld
+
+// PersistentNode is an immutable map of the data model. Its methods never
+// modify the receiver: updates return a new PersistentNode, in O(log n),
+// which shares all the unchanged entries and subtrees with the original.
+// The zero value is an empty node.
+//
+// Like in a Node, keys are the keys stored in the data, where "@" and "\"
+// are escaped (see EscapePathComponent). Values are stored as-is, and must
+// not be modified once set; NewPersistentNode converts nested Nodes as
+// well.
+//
+// PersistentNode implements Map, so Walk, GetPath, SetPath and DeletePath
+// traverse it like a Node. It must be converted to a Node to be encoded.
+type PersistentNode struct {
+	root *hamtNode
+	size int
+}
+
+// NewPersistentNode returns a PersistentNode holding the entries of n.
+// Nested maps other than links are converted to PersistentNodes, and
+// nested lists are copied, so later modifications of n do not change the
+// PersistentNode. Links are copied as Links, so IsLink and LinkCast keep
+// recognizing them.
+func NewPersistentNode(n Node) PersistentNode {
+	var p PersistentNode
+	for k, v := range n {
+		p = p.Set(k, persistentValue(v))
+	}
+	return p
+}
+
+func persistentValue(v interface{}) interface{} {
+	if l, ok := LinkCast(v); ok {
+		c := Link{}
+		for k, lv := range l {
+			c[k] = lv
+		}
+		return c
+	}
+	if _, ok := v.(PersistentNode); ok {
+		return v
+	}
+	if n, ok := AsMap(v); ok {
+		return NewPersistentNode(n)
+	}
+	if s, ok := AsList(v); ok {
+		c := make([]interface{}, len(s))
+		for i, e := range s {
+			c[i] = persistentValue(e)
+		}
+		return c
+	}
+	return v
+}
+
+// Len returns the number of entries of p.
+func (p PersistentNode) Len() int {
+	return p.size
+}
+
+// Lookup returns the value at key, and whether it is present.
+func (p PersistentNode) Lookup(key string) (interface{}, bool) {
+	return p.root.get(key, hashKey(key), 0)
+}
+
+// Keys returns the keys of p, in no particular order.
+func (p PersistentNode) Keys() []string {
+	keys := make([]string, 0, p.size)
+	p.root.each(func(e hamtEntry) {
+		keys = append(keys, e.key)
+	})
+	return keys
+}
+
+// Set returns a node where key is set to value.
+func (p PersistentNode) Set(key string, value interface{}) PersistentNode {
+	root := p.root
+	if root == nil {
+		root = &hamtNode{}
+	}
+	root, added := root.set(key, hashKey(key), 0, value)
+	if added {
+		return PersistentNode{root, p.size + 1}
+	}
+	return PersistentNode{root, p.size}
+}
+
+// Delete returns a node without key. If key is not present, p itself is
+// returned.
+func (p PersistentNode) Delete(key string) PersistentNode {
+	if p.root == nil {
+		return p
+	}
+	root, removed := p.root.remove(key, hashKey(key), 0)
+	if !removed {
+		return p
+	}
+	return PersistentNode{root, p.size - 1}
+}
+
+// SetPath is like the SetPath function, returning a new node. Missing
+// intermediate maps are created as PersistentNodes, and the Nodes and
+// lists along npath are copied, so neither p nor any value within it is
+// modified.
+func (p PersistentNode) SetPath(npath Path, value interface{}) (PersistentNode, error) {
+	if len(npath) == 0 {
+		return p, ErrEmptyPath
+	}
+	res, err := setPath(p, npath, 0, value, true)
+	if err != nil {
+		return p, err
+	}
+	return res.(PersistentNode), nil
+}
+
+// DeletePath is like the DeletePathCopy function, returning a new node.
+// If npath does not exist, p itself is returned.
+func (p PersistentNode) DeletePath(npath Path) (PersistentNode, error) {
+	if len(npath) == 0 {
+		return p, ErrEmptyPath
+	}
+	if res, changed := deletePath(p, npath, 0, true); changed {
+		return res.(PersistentNode), nil
+	}
+	return p, nil
+}
+
+// Node returns a Node holding the entries of p. Nested PersistentNodes are
+// converted as well, and nested lists are copied.
+func (p PersistentNode) Node() Node {
+	n := make(Node, p.size)
+	p.root.each(func(e hamtEntry) {
+		n[e.key] = nodeValue(e.value)
+	})
+	return n
+}
+
+func nodeValue(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case PersistentNode:
+		return vt.Node()
+	case []interface{}:
+		c := make([]interface{}, len(vt))
+		for i, e := range vt {
+			c[i] = nodeValue(e)
+		}
+		return c
+	}
+	return v
+}
@@ -0,0 +1,191 @@
+package ipld
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestPersistentNode(t *testing.T) {
+	var p PersistentNode
+	if p.Len() != 0 {
+		t.Fatalf("zero value is not empty")
+	}
+
+	// enough keys to split the root and use several levels.
+	var versions []PersistentNode
+	for i := 0; i < 1000; i++ {
+		p = p.Set(strconv.Itoa(i), i)
+		versions = append(versions, p)
+	}
+	p = p.Set("0", "zero")
+	if p.Len() != 1000 {
+		t.Errorf("expected 1000 entries, got %d", p.Len())
+	}
+
+	// earlier versions are untouched
+	for i, v := range versions {
+		if v.Len() != i+1 {
+			t.Fatalf("version %d has %d entries", i, v.Len())
+		}
+		if x, ok := v.Lookup(strconv.Itoa(i)); !ok || x != i {
+			t.Fatalf("version %d: unexpected value %#v", i, x)
+		}
+		if _, ok := v.Lookup(strconv.Itoa(i + 1)); ok {
+			t.Fatalf("version %d holds a later key", i)
+		}
+	}
+	if x, _ := versions[999].Lookup("0"); x != 0 {
+		t.Errorf("unexpected value %#v", x)
+	}
+
+	keys := p.Keys()
+	sort.Strings(keys)
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			t.Fatalf("duplicate key %s", keys[i])
+		}
+	}
+	if len(keys) != 1000 {
+		t.Errorf("expected 1000 keys, got %d", len(keys))
+	}
+
+	q := p
+	for i := 0; i < 1000; i += 2 {
+		q = q.Delete(strconv.Itoa(i))
+	}
+	if q.Len() != 500 || p.Len() != 1000 {
+		t.Errorf("unexpected sizes %d, %d", q.Len(), p.Len())
+	}
+	for i := 0; i < 1000; i++ {
+		_, ok := q.Lookup(strconv.Itoa(i))
+		if ok != (i%2 == 1) {
+			t.Fatalf("%d: unexpected presence %v", i, ok)
+		}
+	}
+	if r := q.Delete("missing"); r != q {
+		t.Errorf("deleting a missing key changed the node")
+	}
+	for i := 1; i < 1000; i += 2 {
+		q = q.Delete(strconv.Itoa(i))
+	}
+	if q.Len() != 0 || len(q.Keys()) != 0 {
+		t.Errorf("expected an empty node, got %v", q.Keys())
+	}
+}
+
+func TestHamtCollisions(t *testing.T) {
+	// entries with the same hash are kept in the collisions of a node
+	// below the last level.
+	n := &hamtNode{}
+	for _, k := range []string{"a", "b", "c"} {
+		n, _ = n.set(k, 42, 0, k)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if v, ok := n.get(k, 42, 0); !ok || v != k {
+			t.Errorf("%s: unexpected value %#v", k, v)
+		}
+	}
+	n, _ = n.remove("a", 42, 0)
+	n, _ = n.remove("b", 42, 0)
+	if e, ok := n.single(); !ok || e.key != "c" {
+		t.Errorf("expected the last entry folded into the root, got %#v", n)
+	}
+}
+
+func TestPersistentNodeConversion(t *testing.T) {
+	link := Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"}
+	n := Node{
+		"a":    Node{"b": []interface{}{1, Node{"c": 2}}},
+		"link": link,
+		"list": []int{1, 2},
+	}
+	p := NewPersistentNode(n)
+
+	n["a"].(Node)["b"].([]interface{})[0] = "changed"
+	link["name"] = "changed"
+
+	expected := Node{
+		"a":    Node{"b": []interface{}{1, Node{"c": 2}}},
+		"link": Link{"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"},
+		"list": []interface{}{1, 2},
+	}
+	if res := p.Node(); !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %#v, got %#v", expected, res)
+	}
+	if _, ok := GetPath(p, "a/b/1").(PersistentNode); !ok {
+		t.Errorf("nested nodes are not converted")
+	}
+	if _, ok := GetPath(p, "link").(Link); !ok {
+		t.Errorf("links are not kept")
+	}
+}
+
+func TestPersistentNodeTraversal(t *testing.T) {
+	p := NewPersistentNode(Node{
+		"a": Node{"b": 1, "@type": "t"},
+		"l": []interface{}{Node{"c": 2}},
+	})
+
+	if v := GetPath(p, "a/b"); v != 1 {
+		t.Errorf("unexpected value %#v", v)
+	}
+	if v := GetPath(Node{"p": p}, "p/l/0/c"); v != 2 {
+		t.Errorf("unexpected value %#v", v)
+	}
+
+	var paths []string
+	err := WalkOrdered(Node{"p": p}, nil, func(root, curr Node, path Path, err error) error {
+		paths = append(paths, path.String())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"", "p", "p/a", "p/l/0"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	q, err := p.SetPath(ParsePath("a/d/e"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err = q.SetPath(ParsePath("l/0/c"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := GetPath(q, "a/d").(PersistentNode); !ok {
+		t.Errorf("missing maps are not created as PersistentNodes")
+	}
+	if v := GetPath(q, "a/d/e"); v != 3 {
+		t.Errorf("unexpected value %#v", v)
+	}
+	if v := GetPath(q, "l/0/c"); v != 4 {
+		t.Errorf("unexpected value %#v", v)
+	}
+	if v := GetPath(p, "l/0/c"); v != 2 {
+		t.Errorf("original modified: %#v", v)
+	}
+
+	q, err = q.DeletePath(ParsePath("a/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := GetPath(q, "a/b"); v != nil || GetPath(p, "a/b") != 1 {
+		t.Errorf("unexpected values %#v, %#v", v, GetPath(p, "a/b"))
+	}
+	if r, _ := q.DeletePath(ParsePath("x/y")); r != q {
+		t.Errorf("deleting a missing path changed the node")
+	}
+
+	// a Node holding a PersistentNode, updated in place
+	n := Node{"p": p}
+	if _, err := SetPath(n, ParsePath("p/a/b"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if GetPath(n, "p/a/b") != 5 || GetPath(p, "a/b") != 1 {
+		t.Errorf("unexpected values %#v, %#v", GetPath(n, "p/a/b"), GetPath(p, "a/b"))
+	}
+}
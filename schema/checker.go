@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+
+	ipld "github.com/ipfs/go-ipld"
+	traverse "github.com/ipfs/go-ipld/traverse"
+)
+
+// ErrNoSchema is returned when no schema is registered for a node.
+var ErrNoSchema = errors.New("no schema for node")
+
+// Checker validates nodes against schemas.
+type Checker struct {
+	// Registry is used by ValidateNode to find the schema of nodes. If
+	// nil, DefaultRegistry is used.
+	Registry *Registry
+
+	// Loader, if set, is used to load link targets and check their
+	// "@type" against the Target of link fields.
+	Loader traverse.Loader
+}
+
+// Validate checks n against s, and returns an *ipld.ValidationError
+// listing every violation found, or nil.
+func (c *Checker) Validate(n ipld.Node, s *Schema) error {
+	var verr ipld.ValidationError
+
+	if s.Type != "" {
+		if t, ok := n[ipld.TypeKey].(string); ok && t != s.Type {
+			verr.Addf(ipld.TypeKey, "expected @type %q, got %q", s.Type, t)
+		}
+	}
+	c.validateFields(reflect.ValueOf(n), s.Fields, s.Closed, "", &verr)
+
+	return verr.Err()
+}
+
+// ValidateNode looks up the schema of n in the registry and checks n
+// against it. It returns ErrNoSchema if n has no registered schema.
+func (c *Checker) ValidateNode(n ipld.Node) error {
+	r := c.Registry
+	if r == nil {
+		r = DefaultRegistry
+	}
+
+	s := r.Lookup(n)
+	if s == nil {
+		return ErrNoSchema
+	}
+	return c.Validate(n, s)
+}
+
+// validateTarget loads the target of link v and checks its "@type".
+func (c *Checker) validateTarget(v interface{}, target, p string, verr *ipld.ValidationError) {
+	if c.Loader == nil {
+		return
+	}
+
+	l, _ := ipld.LinkCast(v)
+	h, err := l.Hash()
+	if err != nil {
+		verr.Addf(p, "invalid link: %s", err)
+		return
+	}
+
+	n, err := traverse.Load(c.Loader, h)
+	if err != nil {
+		verr.Addf(p, "cannot load link target: %s", err)
+		return
+	}
+
+	if t := n.Type(); t != target {
+		verr.Addf(p, "expected link to %q, got %q", target, t)
+	}
+}
@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"sync"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// DefaultRegistry is the registry used by the package level functions.
+var DefaultRegistry = NewRegistry()
+
+// Registry maps "@type" and "@context" values to schemas. It is safe for
+// concurrent use.
+type Registry struct {
+	lk       sync.RWMutex
+	types    map[string]*Schema
+	contexts map[string]*Schema
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		types:    map[string]*Schema{},
+		contexts: map[string]*Schema{},
+	}
+}
+
+// Register registers s for the nodes of type s.Type, replacing any
+// previous schema for that type.
+func (r *Registry) Register(s *Schema) {
+	r.lk.Lock()
+	r.types[s.Type] = s
+	r.lk.Unlock()
+}
+
+// RegisterContext registers s for the nodes using the given context,
+// usually the path to a context stored in IPFS:
+//
+//   /ipfs/Qmf1ec6n9f8kW8JTLjqaZceJVpDpZD4L3aPoJFvssBE7Eb/merkleweb
+//
+func (r *Registry) RegisterContext(ctx string, s *Schema) {
+	r.lk.Lock()
+	r.contexts[ctx] = s
+	r.lk.Unlock()
+}
+
+// Lookup returns the schema of n, or nil. The "@type" of n is looked up
+// first, then its "@context". If the context is a list, the first
+// registered context wins.
+func (r *Registry) Lookup(n ipld.Node) *Schema {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	if t := n.Type(); t != "" {
+		if s, ok := r.types[t]; ok {
+			return s
+		}
+	}
+
+	switch ctx := n.Context().(type) {
+	case string:
+		return r.contexts[ctx]
+	case []interface{}:
+		for _, c := range ctx {
+			if cs, ok := c.(string); ok {
+				if s, ok := r.contexts[cs]; ok {
+					return s
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Register registers s in DefaultRegistry.
+func Register(s *Schema) {
+	DefaultRegistry.Register(s)
+}
+
+// RegisterContext registers s for ctx in DefaultRegistry.
+func RegisterContext(ctx string, s *Schema) {
+	DefaultRegistry.RegisterContext(ctx, s)
+}
+
+// Lookup returns the schema of n in DefaultRegistry.
+func Lookup(n ipld.Node) *Schema {
+	return DefaultRegistry.Lookup(n)
+}
+
+// ValidateNode checks n against its schema in DefaultRegistry.
+func ValidateNode(n ipld.Node) error {
+	c := &Checker{}
+	return c.ValidateNode(n)
+}
@@ -0,0 +1,276 @@
+// Package schema describes the expected shape of IPLD nodes and checks
+// nodes against these descriptions.
+//
+// Schemas are IPLD nodes themselves, so they can be stored and linked to
+// like any other node. A schema extends a JSON-LD context with the shape
+// of the nodes of a given type:
+//
+//   {
+//     "@context": { "mlink": "merkle-link" },
+//     "type": "commit",
+//     "closed": false,
+//     "fields": {
+//       "parents": {
+//         "kind": "list",
+//         "required": true,
+//         "items": { "kind": "link", "target": "commit" }
+//       },
+//       "author":  { "kind": "link", "required": true, "target": "authorship" },
+//       "comment": { "kind": "string" },
+//       "meta":    { "kind": "map", "values": { "kind": "string" } }
+//     }
+//   }
+//
+// Field names are node keys, as found in the serialized node. Fields of
+// kind "map" may describe their own shape with "fields" and "closed".
+package schema
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// Kind is the kind of value a field holds.
+type Kind string
+
+// These are the kinds of values in the IPLD data model.
+const (
+	Any    Kind = "any"    // anything, the default
+	Null   Kind = "null"   // nil
+	Bool   Kind = "bool"   // true or false
+	Int    Kind = "int"    // integral numbers
+	Float  Kind = "float"  // any number
+	String Kind = "string" // text
+	Bytes  Kind = "bytes"  // binary data
+	List   Kind = "list"   // sequences
+	Map    Kind = "map"    // nodes
+	Link   Kind = "link"   // merkle-links
+)
+
+var kinds = map[Kind]bool{
+	Any: true, Null: true, Bool: true, Int: true, Float: true,
+	String: true, Bytes: true, List: true, Map: true, Link: true,
+}
+
+// Schema describes the nodes of a given type.
+type Schema struct {
+	// Context is the JSON-LD context of the described nodes.
+	Context interface{} `ipld:"@context,omitempty"`
+
+	// Type is the "@type" of the described nodes. Nodes with a different
+	// "@type" are invalid. Nodes without "@type" are accepted.
+	Type string `ipld:"type,omitempty"`
+
+	// Fields describes the keys of the nodes.
+	Fields map[string]*Field `ipld:"fields,omitempty"`
+
+	// Closed makes keys which are not in Fields invalid. Directives
+	// (keys starting with "@") are always allowed.
+	Closed bool `ipld:"closed,omitempty"`
+}
+
+// Field describes the value of a key.
+type Field struct {
+	Kind     Kind `ipld:"kind,omitempty"`
+	Required bool `ipld:"required,omitempty"`
+
+	// Target is the expected "@type" of the node a link points to. It
+	// is only checked by a Checker with a Loader.
+	Target string `ipld:"target,omitempty"`
+
+	// Items describes the elements of a list.
+	Items *Field `ipld:"items,omitempty"`
+
+	// Values describes all the values of a map.
+	Values *Field `ipld:"values,omitempty"`
+
+	// Fields and Closed describe the keys of a map, like in Schema.
+	Fields map[string]*Field `ipld:"fields,omitempty"`
+	Closed bool              `ipld:"closed,omitempty"`
+}
+
+// IPLDValidate checks the field kind is known.
+func (f *Field) IPLDValidate() error {
+	var verr ipld.ValidationError
+	if f.Kind != "" && !kinds[f.Kind] {
+		verr.Addf("kind", "unknown kind %q", f.Kind)
+	}
+	if f.Items != nil && f.Kind != List {
+		verr.Add("items", "only allowed on lists")
+	}
+	if (f.Values != nil || f.Fields != nil) && f.Kind != Map {
+		verr.Add("fields", "only allowed on maps")
+	}
+	if f.Target != "" && f.Kind != Link {
+		verr.Add("target", "only allowed on links")
+	}
+	return verr.Err()
+}
+
+// Parse reads a schema from its node representation.
+func Parse(n ipld.Node) (*Schema, error) {
+	var s Schema
+	if err := ipld.Unmarshal(n, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Node returns the node representation of the schema.
+func (s *Schema) Node() (ipld.Node, error) {
+	return ipld.Marshal(s)
+}
+
+// Validate checks n against s, and returns an *ipld.ValidationError
+// listing every violation found, or nil. Link targets are not checked,
+// see Checker for this.
+func Validate(n ipld.Node, s *Schema) error {
+	c := &Checker{}
+	return c.Validate(n, s)
+}
+
+// kindOf returns the kind of a value in the IPLD data model, or "" if the
+// value is not part of it.
+func kindOf(v interface{}) Kind {
+	if v == nil {
+		return Null
+	}
+	if ipld.IsLink(v) {
+		return Link
+	}
+
+	switch v.(type) {
+	case *big.Int:
+		return Int
+	case ipld.Float:
+		return Float
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Int
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return Int // stdlib JSON decoders produce floats
+		}
+		return Float
+	case reflect.String:
+		return String
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return Bytes
+		}
+		return List
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			return Map
+		}
+	}
+	return ""
+}
+
+// matches returns whether a value of kind k is valid for a field of
+// kind expected.
+func matches(expected, k Kind) bool {
+	switch expected {
+	case "", Any:
+		return true
+	case Float:
+		return k == Float || k == Int
+	case Map:
+		return k == Map || k == Link // links are maps with extra meaning
+	}
+	return expected == k
+}
+
+// sortedKeys returns the keys of the string-keyed map m in order, so
+// failures are always reported in the same order.
+func sortedKeys(m reflect.Value) []string {
+	keys := make([]string, 0, m.Len())
+	for _, k := range m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateFields checks the keys of m against fields.
+func (c *Checker) validateFields(m reflect.Value, fields map[string]*Field, closed bool, p string, verr *ipld.ValidationError) {
+	for _, name := range sortedKeys(reflect.ValueOf(fields)) {
+		f := fields[name]
+		if f == nil {
+			f = &Field{}
+		}
+		v := m.MapIndex(reflect.ValueOf(name).Convert(m.Type().Key()))
+		if !v.IsValid() {
+			if f.Required {
+				verr.Add(path.Join(p, name), "missing required key")
+			}
+			continue
+		}
+		c.validateValue(v.Interface(), f, path.Join(p, name), verr)
+	}
+
+	if !closed {
+		return
+	}
+	for _, name := range sortedKeys(m) {
+		if len(name) > 0 && name[0] == '@' {
+			continue // directive
+		}
+		if _, ok := fields[name]; !ok {
+			verr.Add(path.Join(p, name), "unexpected key")
+		}
+	}
+}
+
+// validateValue checks v against f.
+func (c *Checker) validateValue(v interface{}, f *Field, p string, verr *ipld.ValidationError) {
+	k := kindOf(v)
+	if !matches(f.Kind, k) {
+		if k == "" {
+			k = Kind(fmt.Sprintf("%T", v))
+		}
+		verr.Addf(p, "expected %s, got %s", f.Kind, k)
+		return
+	}
+
+	rv := reflect.ValueOf(v)
+	switch k {
+	case List:
+		if f.Items == nil {
+			return
+		}
+		for i := 0; i < rv.Len(); i++ {
+			c.validateValue(rv.Index(i).Interface(), f.Items, path.Join(p, strconv.Itoa(i)), verr)
+		}
+
+	case Map:
+		if f.Values != nil {
+			for _, key := range sortedKeys(rv) {
+				kv := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+				c.validateValue(kv.Interface(), f.Values, path.Join(p, key), verr)
+			}
+		}
+		if f.Fields != nil || f.Closed {
+			c.validateFields(rv, f.Fields, f.Closed, p, verr)
+		}
+
+	case Link:
+		if f.Target != "" {
+			c.validateTarget(v, f.Target, p, verr)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	ipld "github.com/ipfs/go-ipld"
+	store "github.com/ipfs/go-ipld/store"
+)
+
+var commitSchemaNode = ipld.Node{
+	"@context": ipld.Node{"mlink": "merkle-link"},
+	"type":     "commit",
+	"closed":   true,
+	"fields": ipld.Node{
+		"parents": ipld.Node{
+			"kind":     "list",
+			"required": true,
+			"items":    ipld.Node{"kind": "link", "target": "commit"},
+		},
+		"author":  ipld.Node{"kind": "link", "required": true},
+		"comment": ipld.Node{"kind": "string"},
+		"date":    ipld.Node{"kind": "int"},
+		"meta": ipld.Node{
+			"kind":   "map",
+			"values": ipld.Node{"kind": "string"},
+		},
+		"size": ipld.Node{
+			"kind":   "map",
+			"closed": true,
+			"fields": ipld.Node{
+				"value": ipld.Node{"kind": "float", "required": true},
+			},
+		},
+	},
+}
+
+const testHash = "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo"
+
+func failures(t *testing.T, err error) map[string]string {
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*ipld.ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	res := map[string]string{}
+	for _, f := range verr.Failures {
+		res[f.Path] = f.Reason
+	}
+	return res
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse(commitSchemaNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Type != "commit" || !s.Closed || len(s.Fields) != 6 {
+		t.Errorf("unexpected schema %#v", s)
+	}
+	if s.Fields["parents"].Items.Target != "commit" {
+		t.Errorf("unexpected parents field %#v", s.Fields["parents"])
+	}
+
+	n, err := s.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := Parse(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, s2) {
+		t.Errorf("schema does not round-trip: %#v", s2)
+	}
+
+	_, err = Parse(ipld.Node{
+		"fields": ipld.Node{
+			"foo": ipld.Node{"kind": "strnig"},
+			"bar": ipld.Node{"kind": "string", "target": "commit"},
+		},
+	})
+	f := failures(t, err)
+	if len(f) != 2 || f["fields/foo/kind"] == "" || f["fields/bar/target"] == "" {
+		t.Errorf("unexpected parse errors: %#v", f)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Parse(commitSchemaNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := ipld.Node{
+		"@type":   "commit",
+		"parents": []interface{}{ipld.Node{"mlink": testHash}},
+		"author":  ipld.Node{"mlink": testHash},
+		"date":    ipld.Int(1444000000), // as decoded by the codecs
+		"meta":    ipld.Node{"foo": "bar"},
+		"size":    ipld.Node{"value": ipld.Int(12)},
+	}
+	if err := Validate(valid, s); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	invalid := ipld.Node{
+		"@type":   "tree",
+		"parents": []interface{}{ipld.Node{"mlink": testHash}, "foo"},
+		"comment": 12,
+		"date":    ipld.Float(1444000000),
+		"meta":    ipld.Node{"foo": []byte("bar")},
+		"size":    ipld.Node{"other": 1},
+		"extra":   true,
+	}
+	expected := map[string]string{
+		"@type":      `expected @type "commit", got "tree"`,
+		"parents/1":  "expected link, got string",
+		"author":     "missing required key",
+		"comment":    "expected string, got int",
+		"date":       "expected int, got float",
+		"meta/foo":   "expected string, got bytes",
+		"size/value": "missing required key",
+		"size/other": "unexpected key",
+		"extra":      "unexpected key",
+	}
+	if f := failures(t, Validate(invalid, s)); !reflect.DeepEqual(f, expected) {
+		t.Errorf("unexpected failures:\n%#v", f)
+	}
+
+	// failures are reported in the same order on every run.
+	msg := Validate(invalid, s).Error()
+	for i := 0; i < 20; i++ {
+		if m := Validate(invalid, s).Error(); m != msg {
+			t.Fatalf("unstable failure order:\n%s\n%s", msg, m)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	s, err := Parse(commitSchemaNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const ctx = "/ipfs/Qmf1ec6n9f8kW8JTLjqaZceJVpDpZD4L3aPoJFvssBE7Eb/merkleweb"
+	r := NewRegistry()
+	r.Register(s)
+	r.RegisterContext(ctx, s)
+
+	cases := []struct {
+		n      ipld.Node
+		schema *Schema
+	}{
+		{ipld.Node{"@type": "commit"}, s},
+		{ipld.Node{"@context": ctx}, s},
+		{ipld.Node{"@context": []interface{}{"/ipfs/foo", ctx}}, s},
+		{ipld.Node{"@type": "tree"}, nil},
+		{ipld.Node{}, nil},
+	}
+	for _, c := range cases {
+		if found := r.Lookup(c.n); found != c.schema {
+			t.Errorf("%#v: unexpected schema %#v", c.n, found)
+		}
+	}
+
+	checker := &Checker{Registry: r}
+	if err := checker.ValidateNode(ipld.Node{"@type": "tree"}); err != ErrNoSchema {
+		t.Errorf("expected ErrNoSchema, got %v", err)
+	}
+	if f := failures(t, checker.ValidateNode(ipld.Node{"@context": ctx})); len(f) != 2 {
+		t.Errorf("unexpected failures: %#v", f)
+	}
+}
+
+func TestLinkTargets(t *testing.T) {
+	s, err := Parse(commitSchemaNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := store.NewMemoryStore()
+	commit, err := bs.Put(ipld.Node{"@type": "commit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := bs.Put(ipld.Node{"@type": "tree"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := ipld.Node{
+		"parents": []interface{}{
+			ipld.Node{"mlink": commit.B58String()},
+			ipld.Node{"mlink": tree.B58String()},
+		},
+		"author": ipld.Node{"mlink": tree.B58String()},
+	}
+
+	// without loader, targets are not checked.
+	if err := Validate(n, s); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	checker := &Checker{Loader: bs}
+	expected := map[string]string{
+		"parents/1": `expected link to "commit", got "tree"`,
+	}
+	if f := failures(t, checker.Validate(n, s)); !reflect.DeepEqual(f, expected) {
+		t.Errorf("unexpected failures: %#v", f)
+	}
+}
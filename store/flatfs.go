@@ -0,0 +1,146 @@
+package store
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// FileStore is a Store keeping one file per block on disk. Blocks are
+// sharded in sub-directories named after the first byte (in hex) of the
+// hash digest, to keep directories reasonably small:
+//
+//   <root>/1f/QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo
+//
+// Blocks read from disk are hashed again, and ErrHashMismatch is returned
+// for files which were corrupted or tampered with.
+type FileStore struct {
+	root string
+}
+
+// NewFileStore returns a FileStore rooted at the given directory, which is
+// created if it does not exist.
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{root: root}, nil
+}
+
+// Root returns the directory in which blocks are stored.
+func (s *FileStore) Root() string {
+	return s.root
+}
+
+// path returns the shard directory and file path of the block at h.
+func (s *FileStore) path(h mh.Multihash) (dir, file string, err error) {
+	dh, err := mh.Decode(h)
+	if err != nil {
+		return "", "", err
+	}
+
+	shard := "00"
+	if len(dh.Digest) > 0 {
+		shard = hex.EncodeToString(dh.Digest[:1])
+	}
+	dir = filepath.Join(s.root, shard)
+	return dir, filepath.Join(dir, h.B58String()), nil
+}
+
+func (s *FileStore) Put(n ipld.Node) (mh.Multihash, error) {
+	data, h, err := Encode(n)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, file, err := s.path(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(file); err == nil {
+		return h, nil // content-addressed, already there.
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// write to a temporary file first so readers never see partial blocks.
+	tmp, err := ioutil.TempFile(dir, ".put-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return h, nil
+}
+
+func (s *FileStore) Get(h mh.Multihash) (ipld.Node, error) {
+	data, err := s.GetBlock(h)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+func (s *FileStore) GetBlock(h mh.Multihash) ([]byte, error) {
+	_, file, err := s.path(h)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := Verify(h, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *FileStore) Has(h mh.Multihash) (bool, error) {
+	_, file, err := s.path(h)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *FileStore) Delete(h mh.Multihash) error {
+	_, file, err := s.path(h)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
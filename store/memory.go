@@ -0,0 +1,65 @@
+package store
+
+import (
+	"sync"
+
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+// MemoryStore is a Store keeping all blocks in memory. It is safe for
+// concurrent use.
+type MemoryStore struct {
+	lk     sync.RWMutex
+	blocks map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blocks: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Put(n ipld.Node) (mh.Multihash, error) {
+	data, h, err := Encode(n)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lk.Lock()
+	s.blocks[string(h)] = data
+	s.lk.Unlock()
+	return h, nil
+}
+
+func (s *MemoryStore) Get(h mh.Multihash) (ipld.Node, error) {
+	data, err := s.GetBlock(h)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+func (s *MemoryStore) GetBlock(h mh.Multihash) ([]byte, error) {
+	s.lk.RLock()
+	data, ok := s.blocks[string(h)]
+	s.lk.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func (s *MemoryStore) Has(h mh.Multihash) (bool, error) {
+	s.lk.RLock()
+	_, ok := s.blocks[string(h)]
+	s.lk.RUnlock()
+	return ok, nil
+}
+
+func (s *MemoryStore) Delete(h mh.Multihash) error {
+	s.lk.Lock()
+	delete(s.blocks, string(h))
+	s.lk.Unlock()
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package store provides content-addressed storage for IPLD nodes.
+//
+// Nodes are serialized with the coding multicodec (which honours the
+// "@codec" directive of each node) and indexed by the multihash of the
+// serialized bytes.
+package store
+
+import (
+	"bytes"
+	"errors"
+
+	mc "github.com/jbenet/go-multicodec"
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+	coding "github.com/ipfs/go-ipld/coding"
+)
+
+// ErrNotFound is returned when a block is not present in a Store.
+var ErrNotFound = errors.New("block not found")
+
+// ErrHashMismatch is returned when the bytes of a stored block do not hash
+// to the key they are stored at.
+var ErrHashMismatch = errors.New("block does not match its hash")
+
+// DefaultHashFunc is the multihash function used to compute keys.
+var DefaultHashFunc = ipld.DefaultHashFunc
+
+// Store is a content-addressed block store. Blocks are keyed by the
+// multihash of their serialized representation.
+type Store interface {
+	// Put serializes n and stores it, returning its multihash.
+	Put(n ipld.Node) (mh.Multihash, error)
+
+	// Get retrieves and decodes the node stored at h. It returns
+	// ErrNotFound if there is no such node.
+	Get(h mh.Multihash) (ipld.Node, error)
+
+	// GetBlock retrieves the serialized bytes stored at h. It returns
+	// ErrNotFound if there is no such block.
+	GetBlock(h mh.Multihash) ([]byte, error)
+
+	// Has returns whether a node is stored at h.
+	Has(h mh.Multihash) (bool, error)
+
+	// Delete removes the node stored at h. Deleting a missing node is
+	// not an error.
+	Delete(h mh.Multihash) error
+}
+
+// PutValue marshals v with ipld.Marshal and stores the resulting node. If
+// v does not pass ipld.Validate, nothing is stored and the
+// *ipld.ValidationError is returned.
+func PutValue(s Store, v interface{}) (mh.Multihash, error) {
+	if err := ipld.Validate(v); err != nil {
+		return nil, err
+	}
+
+	n, err := ipld.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return s.Put(n)
+}
+
+// GetValue retrieves the node stored at h and unmarshals it in the value
+// pointed to by v, with ipld.Unmarshal. Validation errors are returned as
+// *ipld.ValidationError.
+func GetValue(s Store, h mh.Multihash, v interface{}) error {
+	n, err := s.Get(h)
+	if err != nil {
+		return err
+	}
+	return ipld.Unmarshal(n, v)
+}
+
+// Encode serializes n using the coding multicodec and returns the encoded
+// bytes along with their multihash.
+func Encode(n ipld.Node) ([]byte, mh.Multihash, error) {
+	data, err := mc.Marshal(coding.Multicodec(), &n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h, err := ipld.Sum(data, DefaultHashFunc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, h, nil
+}
+
+// Decode deserializes a block previously produced by Encode.
+func Decode(data []byte) (ipld.Node, error) {
+	var n ipld.Node
+	if err := mc.Unmarshal(coding.Multicodec(), data, &n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Verify checks that data hashes to h, using the hash function h was
+// computed with. It returns ErrHashMismatch if it does not.
+func Verify(h mh.Multihash, data []byte) error {
+	dh, err := mh.Decode(h)
+	if err != nil {
+		return err
+	}
+
+	sum, err := ipld.Sum(data, dh.Code)
+	if err != nil {
+		return err
+	}
+	ds, err := mh.Decode(sum)
+	if err != nil {
+		return err
+	}
+
+	// h may hold a truncated digest.
+	if len(ds.Digest) < len(dh.Digest) || !bytes.Equal(ds.Digest[:len(dh.Digest)], dh.Digest) {
+		return ErrHashMismatch
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+var testNodes = []ipld.Node{
+	ipld.Node{
+		"foo": "bar",
+	},
+	ipld.Node{
+		"@codec": "/json",
+		"foo":    "bar",
+		"baz": ipld.Node{
+			"mlink": "QmZku7P7KeeHAnwMr6c4HveYfMzmtVinNXzibkiNbfDbPo",
+		},
+	},
+}
+
+func testStore(t *testing.T, s Store) {
+	var hashes []mh.Multihash
+	for i, n := range testNodes {
+		h, err := s.Put(n)
+		if err != nil {
+			t.Fatalf("put #%d: %s", i, err)
+		}
+		hashes = append(hashes, h)
+
+		h2, err := s.Put(n)
+		if err != nil {
+			t.Fatalf("put #%d again: %s", i, err)
+		}
+		if !reflect.DeepEqual(h, h2) {
+			t.Errorf("put #%d is not stable: %s != %s", i, h.B58String(), h2.B58String())
+		}
+	}
+
+	for i, h := range hashes {
+		has, err := s.Has(h)
+		if err != nil || !has {
+			t.Errorf("has #%d: %v, %v", i, has, err)
+		}
+
+		n, err := s.Get(h)
+		if err != nil {
+			t.Fatalf("get #%d: %s", i, err)
+		}
+		if !reflect.DeepEqual(testNodes[i].Links(), n.Links()) {
+			t.Errorf("get #%d: links mismatch %#v", i, n.Links())
+		}
+		if n["foo"] != "bar" {
+			t.Errorf("get #%d: unexpected node %#v", i, n)
+		}
+	}
+
+	for i, h := range hashes {
+		if err := s.Delete(h); err != nil {
+			t.Errorf("delete #%d: %s", i, err)
+		}
+		if err := s.Delete(h); err != nil {
+			t.Errorf("delete #%d again: %s", i, err)
+		}
+		if has, err := s.Has(h); err != nil || has {
+			t.Errorf("has #%d after delete: %v, %v", i, has, err)
+		}
+		if _, err := s.Get(h); err != ErrNotFound {
+			t.Errorf("get #%d after delete: expected ErrNotFound, got %v", i, err)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipld-store-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+}
+
+func TestFileStoreCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipld-store-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := s.Put(testNodes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, file, err := s.path(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetBlock(h); err != ErrHashMismatch {
+		t.Errorf("get block: expected ErrHashMismatch, got %v", err)
+	}
+	if _, err := s.Get(h); err != ErrHashMismatch {
+		t.Errorf("get: expected ErrHashMismatch, got %v", err)
+	}
+}
+
+type testFile struct {
+	Name string
+	Data []byte
+}
+
+func (f *testFile) IPLDValidate() error {
+	var verr ipld.ValidationError
+	if f.Name == "" {
+		verr.Add("name", "name is required")
+	}
+	return verr.Err()
+}
+
+func TestValues(t *testing.T) {
+	s := NewMemoryStore()
+
+	if _, err := PutValue(s, &testFile{Data: []byte("foo")}); err == nil {
+		t.Error("expected validation error")
+	} else if _, ok := err.(*ipld.ValidationError); !ok {
+		t.Errorf("expected ValidationError, got %v", err)
+	}
+
+	f := &testFile{Name: "foo", Data: []byte("bar")}
+	h, err := PutValue(s, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var f2 testFile
+	if err := GetValue(s, h, &f2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, &f2) {
+		t.Errorf("expected %#v, got %#v", f, &f2)
+	}
+
+	h, err = s.Put(ipld.Node{"title": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f3 testFile
+	if err := GetValue(s, h, &f3); err == nil {
+		t.Error("expected validation error")
+	}
+}
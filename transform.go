@@ -2,8 +2,8 @@ package ipld
 
 import (
 	"errors"
+	"reflect"
 	"strconv"
-	"path"
 )
 
 // TransformFunc is the type of the function called for each node visited by
@@ -21,17 +21,29 @@ import (
 // TransformFunc may return an error. If the error is the special SkipNode
 // error, the children of curr are skipped. All other errors halt processing
 // early.
-type TransformFunc func(root, curr Node, path []string, err error) (Node, error)
+type TransformFunc func(root, curr Node, path Path, err error) (Node, error)
 
 // Transform traverses the given root node and all its children, calling
 // TransformFunc with every Node visited, including root. All errors that arise
 // while visiting nodes are passed to given TransformFunc. The traversing
-// algorithm is the same as the Walk function.
+// algorithm is the same as the Walk function, except that the children of
+// maps are visited in canonical order (see CanonicalKeyLess), so
+// TransformFunc is called in the same order for the same input.
 //
 // Transform returns a node constructed from the different nodes returned by
-// TransformFunc.
+// TransformFunc. Lists which may hold nodes are returned as []interface{},
+// and maps other than Link as Node.
 func Transform(root Node, transformFn TransformFunc) (Node, error) {
-	n, err := transform(root, root, nil, transformFn)
+	return TransformOrdered(root, CanonicalKeyLess, transformFn)
+}
+
+// TransformOrdered is just like Transform, but visits the children of maps
+// in the order set by less. If less is nil, CanonicalKeyLess is used.
+func TransformOrdered(root Node, less KeyLess, transformFn TransformFunc) (Node, error) {
+	if less == nil {
+		less = CanonicalKeyLess
+	}
+	n, err := transform(root, root, nil, transformFn, less)
 	if node, ok := n.(Node); ok {
 		return node, err
 	} else {
@@ -41,18 +53,18 @@ func Transform(root Node, transformFn TransformFunc) (Node, error) {
 
 // TransformFrom is just like Transform, but starts the Walk at given startFrom
 // sub-node.
-func TransformFrom(root Node, startFrom []string, transformFn TransformFunc) (interface{}, error) {
+func TransformFrom(root Node, startFrom Path, transformFn TransformFunc) (interface{}, error) {
 	start := GetPathCmp(root, startFrom)
 	if start == nil {
-		return nil, errors.New("no descendant at " + path.Join(startFrom...))
+		return nil, errors.New("no descendant at " + startFrom.String())
 	}
-	return transform(root, start, startFrom, transformFn)
+	return transform(root, start, startFrom, transformFn, CanonicalKeyLess)
 }
 
 // transform is used to implement Transform
-func transform(root Node, curr interface{}, npath []string, transformFunc TransformFunc) (interface{}, error) {
+func transform(root Node, curr interface{}, npath Path, transformFunc TransformFunc, less KeyLess) (interface{}, error) {
 
-	if nc, ok := curr.(Node); ok { // it's a node!
+	if nc, ok := AsMap(curr); ok { // it's a node!
 		// first, call user's WalkFunc.
 		newnode, err := transformFunc(root, nc, npath, nil)
 		res := Node{}
@@ -65,8 +77,8 @@ func transform(root Node, curr interface{}, npath []string, transformFunc Transf
 		}
 
 		// then recurse.
-		for k, v := range nc {
-			n, err := transform(root, v, append(npath, k), transformFunc)
+		for _, k := range mapKeys(nc, less) {
+			n, err := transform(root, nc[k], npath.Append(k), transformFunc, less)
 			if err != nil {
 				return nil, err
 			} else if n != nil {
@@ -74,13 +86,16 @@ func transform(root Node, curr interface{}, npath []string, transformFunc Transf
 			}
 		}
 
+		if _, isLink := curr.(Link); isLink {
+			return Link(res), nil
+		}
 		return res, nil
 
-	} else if sc, ok := curr.([]interface{}); ok { // it's a slice!
+	} else if sc, ok := AsList(curr); ok && !scalarList(curr) { // it's a slice!
 		res := []interface{}{}
 		for i, v := range sc {
 			k := strconv.Itoa(i)
-			n, err := transform(root, v, append(npath, k), transformFunc)
+			n, err := transform(root, v, npath.Append(k), transformFunc, less)
 			if err != nil {
 				return nil, err
 			} else if n != nil {
@@ -95,3 +110,12 @@ func transform(root Node, curr interface{}, npath []string, transformFunc Transf
 	return curr, nil
 }
 
+// scalarList returns whether v is a list whose element type cannot hold
+// nodes, like []int, so it can be kept as-is.
+func scalarList(v interface{}) bool {
+	switch reflect.TypeOf(v).Elem().Kind() {
+	case reflect.Interface, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package traverse
+
+import (
+	"errors"
+
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+)
+
+const ipfsPrefix = "ipfs"
+
+// ErrInvalidPath is returned when a path does not start with a multihash.
+var ErrInvalidPath = errors.New("invalid path, must start with /<multihash>")
+
+// Resolver resolves paths spanning over multiple blocks, following the
+// merkle-links found along the way.
+type Resolver struct {
+	Loader Loader
+}
+
+// NewResolver returns a Resolver loading blocks from loader.
+func NewResolver(loader Loader) *Resolver {
+	return &Resolver{Loader: loader}
+}
+
+// Resolve resolves a path of the form:
+//
+//   /<multihash>/foo/bar/0/baz
+//   /ipfs/<multihash>/foo/bar/0/baz
+//
+// The path is parsed with ipld.ParsePath, so "/" may be escaped within a
+// component.
+//
+// The first component is the multihash of the block to start from. The
+// remaining components are looked up like GetPathCmp does, but every link
+// found on the way (including the final value) is replaced by the root of
+// the block it points to.
+//
+// Resolve returns the value found, the multihash of the block containing
+// it and the path components which could not be resolved. If rest is not
+// empty, value is the last value that could be reached, and rest[0] is
+// the component which does not exist in it.
+func (r *Resolver) Resolve(p string) (value interface{}, blk mh.Multihash, rest ipld.Path, err error) {
+	cmps := ipld.ParsePath(p)
+	if len(cmps) > 0 && cmps[0] == ipfsPrefix {
+		cmps = cmps[1:]
+	}
+	if len(cmps) == 0 {
+		return nil, nil, nil, ErrInvalidPath
+	}
+
+	blk, err = mh.FromB58String(cmps[0])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidPath
+	}
+
+	root, err := Load(r.Loader, blk)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return r.resolve(root, blk, cmps[1:])
+}
+
+// ResolveFrom is like Resolve, but starts at the given root node, which
+// does not need to be stored. The returned blk is nil if the value lies
+// within root itself.
+func (r *Resolver) ResolveFrom(root ipld.Node, p ipld.Path) (value interface{}, blk mh.Multihash, rest ipld.Path, err error) {
+	return r.resolve(root, nil, p)
+}
+
+func (r *Resolver) resolve(curr interface{}, blk mh.Multihash, p ipld.Path) (interface{}, mh.Multihash, ipld.Path, error) {
+	// blocks followed since the last path component was consumed, to
+	// detect links pointing to links in a loop.
+	hops := map[string]bool{}
+
+	for {
+		if l, ok := ipld.LinkCast(curr); ok {
+			h, err := l.Hash()
+			if err != nil {
+				return curr, blk, p, err
+			}
+			if hops[string(h)] {
+				return curr, blk, p, ErrCycle
+			}
+			hops[string(h)] = true
+
+			n, err := Load(r.Loader, h)
+			if err != nil {
+				return curr, blk, p, err
+			}
+			curr, blk = n, h
+			continue
+		}
+
+		if len(p) == 0 {
+			return curr, blk, nil, nil
+		}
+
+		next := ipld.GetPathCmp(curr, p[:1])
+		if next == nil {
+			return curr, blk, p, nil
+		}
+		curr, p = next, p[1:]
+		hops = map[string]bool{}
+	}
+}
@@ -0,0 +1,72 @@
+package traverse
+
+import (
+	"reflect"
+	"testing"
+
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+	store "github.com/ipfs/go-ipld/store"
+)
+
+func TestResolve(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	leaf, err := s.Put(ipld.Node{"baz": "qux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid, err := s.Put(ipld.Node{"bar": []interface{}{link(leaf)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := s.Put(ipld.Node{"foo": link(mid)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		value interface{}
+		blk   mh.Multihash
+		rest  ipld.Path
+	}
+	cases := []struct {
+		path   string
+		expect result
+	}{
+		{"/" + root.B58String() + "/foo/bar/0/baz", result{"qux", leaf, nil}},
+		{"/ipfs/" + root.B58String() + "/foo/bar/0", result{ipld.Node{"baz": "qux"}, leaf, nil}},
+		{"/" + root.B58String() + "/foo/bar/0/baz/", result{"qux", leaf, nil}},
+		{"/" + mid.B58String() + "/bar/0/baz", result{"qux", leaf, nil}},
+		{"/" + root.B58String() + "/foo/bar/1/baz", result{[]interface{}{link(leaf)}, mid, ipld.Path{"1", "baz"}}},
+		{"/" + root.B58String() + "/foo/bar/0/baz/quux", result{"qux", leaf, ipld.Path{"quux"}}},
+	}
+
+	r := NewResolver(s)
+	for _, c := range cases {
+		v, blk, rest, err := r.Resolve(c.path)
+		if err != nil {
+			t.Errorf("%s: %s", c.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, c.expect.value) {
+			t.Errorf("%s: got value %#v", c.path, v)
+		}
+		if !reflect.DeepEqual(blk, c.expect.blk) {
+			t.Errorf("%s: got block %s", c.path, blk.B58String())
+		}
+		if !reflect.DeepEqual(rest, c.expect.rest) {
+			t.Errorf("%s: got rest %#v", c.path, rest)
+		}
+	}
+
+	if _, _, _, err := r.Resolve("/foo/bar"); err != ErrInvalidPath {
+		t.Errorf("expected ErrInvalidPath, got %v", err)
+	}
+
+	v, blk, rest, err := r.ResolveFrom(ipld.Node{"root": link(root)}, ipld.Path{"root", "foo", "bar", "0", "baz"})
+	if err != nil || v != "qux" || !reflect.DeepEqual(blk, leaf) || len(rest) != 0 {
+		t.Errorf("ResolveFrom: %#v %v %#v %v", v, blk, rest, err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package traverse implements a version of ipld.Walk which follows
+// merkle-links across blocks.
+package traverse
+
+import (
+	"errors"
+
+	mc "github.com/jbenet/go-multicodec"
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+	coding "github.com/ipfs/go-ipld/coding"
+)
+
+// ErrCycle is passed to the WalkFunc when a link points back to a block
+// currently being traversed.
+var ErrCycle = errors.New("link cycle detected")
+
+// Loader retrieves the serialized bytes of the block identified by a
+// multihash. The bytes are decoded using the coding multicodec.
+type Loader interface {
+	GetBlock(h mh.Multihash) ([]byte, error)
+}
+
+// LoaderFunc is an adapter to allow the use of ordinary functions as
+// Loader.
+type LoaderFunc func(h mh.Multihash) ([]byte, error)
+
+// GetBlock calls f(h).
+func (f LoaderFunc) GetBlock(h mh.Multihash) ([]byte, error) {
+	return f(h)
+}
+
+// Load retrieves the block identified by h from loader and decodes it.
+func Load(loader Loader, h mh.Multihash) (ipld.Node, error) {
+	data, err := loader.GetBlock(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var n ipld.Node
+	if err := mc.Unmarshal(coding.Multicodec(), data, &n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Traverser walks a graph of nodes, loading linked blocks with Loader.
+type Traverser struct {
+	Loader Loader
+
+	// VisitDuplicates makes the traversal descend into a block every time
+	// it is linked to. By default, each block is only traversed once, the
+	// first time it is reached.
+	VisitDuplicates bool
+}
+
+// Walk traverses root and all the blocks reachable from it through
+// merkle-links, calling walkFn for every Node visited. It behaves like
+// ipld.Walk, except that when a link is visited, and walkFn does not
+// return ipld.SkipNode for it, the target block is loaded and traversed
+// in place of the link properties. The target root node is visited with
+// the same path as the link, so paths span across blocks:
+//
+//   root:      { "foo": { "mlink": "QmA..." } }
+//   QmA...:    { "bar": { "baz": 1 } }
+//
+// visits "", "foo" (the link), "foo" (the root of QmA...) and "foo/bar".
+// The root argument of walkFn is always the node the traversal started
+// from.
+//
+// If the target block cannot be loaded or decoded, or if the link would
+// lead to a cycle, walkFn is called again on the link node with the
+// error, and the target is not traversed.
+func Walk(root ipld.Node, loader Loader, walkFn ipld.WalkFunc) error {
+	t := &Traverser{Loader: loader}
+	return t.Walk(root, walkFn)
+}
+
+// Walk is like the Walk function, using the traverser options.
+func (t *Traverser) Walk(root ipld.Node, walkFn ipld.WalkFunc) error {
+	w := &walker{
+		t:       t,
+		root:    root,
+		walkFn:  walkFn,
+		seen:    map[string]bool{},
+		parents: map[string]bool{},
+	}
+	return w.walk(root, nil)
+}
+
+type walker struct {
+	t       *Traverser
+	root    ipld.Node
+	walkFn  ipld.WalkFunc
+	seen    map[string]bool // blocks already traversed
+	parents map[string]bool // blocks currently being traversed
+}
+
+// walk traverses the block rooted at blk, prefixing all paths with prefix.
+func (w *walker) walk(blk ipld.Node, prefix ipld.Path) error {
+	return ipld.Walk(blk, func(_, curr ipld.Node, p ipld.Path, err error) error {
+		p = prefix.Join(p)
+
+		if err := w.walkFn(w.root, curr, p, err); err != nil {
+			return err
+		}
+
+		l, ok := ipld.LinkCast(curr)
+		if !ok {
+			return nil
+		}
+
+		if err := w.follow(l, p); err != nil {
+			return err
+		}
+		return ipld.SkipNode // link properties are replaced by the target.
+	})
+}
+
+// follow loads the target of l and traverses it at path p.
+func (w *walker) follow(l ipld.Link, p ipld.Path) error {
+	h, err := l.Hash()
+	if err != nil {
+		return w.fail(l, p, err)
+	}
+
+	key := string(h)
+	if w.parents[key] {
+		return w.fail(l, p, ErrCycle)
+	}
+	if w.seen[key] && !w.t.VisitDuplicates {
+		return nil
+	}
+
+	target, err := Load(w.t.Loader, h)
+	if err != nil {
+		return w.fail(l, p, err)
+	}
+
+	w.seen[key] = true
+	w.parents[key] = true
+	err = w.walk(target, p)
+	delete(w.parents, key)
+	return err
+}
+
+// fail reports err on link l to the WalkFunc.
+func (w *walker) fail(l ipld.Link, p ipld.Path, err error) error {
+	err = w.walkFn(w.root, ipld.Node(l), p, err)
+	if err == ipld.SkipNode {
+		return nil
+	}
+	return err
+}
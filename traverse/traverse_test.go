@@ -0,0 +1,179 @@
+package traverse
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	mc "github.com/jbenet/go-multicodec"
+	mh "github.com/jbenet/go-multihash"
+
+	ipld "github.com/ipfs/go-ipld"
+	coding "github.com/ipfs/go-ipld/coding"
+	store "github.com/ipfs/go-ipld/store"
+)
+
+func link(h mh.Multihash) ipld.Node {
+	return ipld.Node{"mlink": h.B58String()}
+}
+
+func collect(t *testing.T, tr *Traverser, root ipld.Node) ([]string, error) {
+	var paths []string
+	err := tr.Walk(root, func(r, curr ipld.Node, p ipld.Path, err error) error {
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(r, root) {
+			t.Errorf("unexpected root at %s: %#v", p, r)
+		}
+		s := p.String()
+		if ipld.IsLink(curr) {
+			s += " (link)"
+		}
+		paths = append(paths, s)
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+func TestWalk(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	leaf, err := s.Put(ipld.Node{"value": "leaf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid, err := s.Put(ipld.Node{
+		"a": link(leaf),
+		"b": []interface{}{link(leaf)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := ipld.Node{
+		"mid":  link(mid),
+		"leaf": link(leaf),
+	}
+
+	paths, err := collect(t, &Traverser{Loader: s}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the leaf block is linked three times but only traversed once, from
+	// whichever link is reached first.
+	leafPaths := 0
+	var rest []string
+	for _, p := range paths {
+		switch p {
+		case "leaf", "mid/a", "mid/b/0":
+			leafPaths++
+		default:
+			rest = append(rest, p)
+		}
+	}
+	expected := []string{
+		"",
+		"leaf (link)",
+		"mid",
+		"mid (link)",
+		"mid/a (link)",
+		"mid/b/0 (link)",
+	}
+	if leafPaths != 1 || !reflect.DeepEqual(expected, rest) {
+		t.Errorf("unexpected paths: %#v", paths)
+	}
+
+	paths, err = collect(t, &Traverser{Loader: s, VisitDuplicates: true}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"",
+		"leaf",
+		"leaf (link)",
+		"mid",
+		"mid (link)",
+		"mid/a",
+		"mid/a (link)",
+		"mid/b/0",
+		"mid/b/0 (link)",
+	}
+	if !reflect.DeepEqual(expected, paths) {
+		t.Errorf("unexpected paths with duplicates: %#v", paths)
+	}
+}
+
+func TestWalkSkipNode(t *testing.T) {
+	s := store.NewMemoryStore()
+	leaf, err := s.Put(ipld.Node{"value": "leaf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := false
+	loader := LoaderFunc(func(h mh.Multihash) ([]byte, error) {
+		loaded = true
+		return s.GetBlock(h)
+	})
+
+	root := ipld.Node{"leaf": link(leaf)}
+	err = Walk(root, loader, func(r, curr ipld.Node, p ipld.Path, err error) error {
+		if ipld.IsLink(curr) {
+			return ipld.SkipNode
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded {
+		t.Error("skipped link was loaded")
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	ha, _ := mh.Sum([]byte("a"), mh.SHA2_256, -1)
+	hb, _ := mh.Sum([]byte("b"), mh.SHA2_256, -1)
+
+	// a loader which does not check hashes, so cycles are possible.
+	blocks := map[string]ipld.Node{
+		string(ha): ipld.Node{"next": link(hb)},
+		string(hb): ipld.Node{"next": link(ha)},
+	}
+	loader := LoaderFunc(func(h mh.Multihash) ([]byte, error) {
+		n, ok := blocks[string(h)]
+		if !ok {
+			return nil, store.ErrNotFound
+		}
+		return mc.Marshal(coding.Multicodec(), &n)
+	})
+
+	var cycleAt string
+	err := Walk(link(ha), loader, func(r, curr ipld.Node, p ipld.Path, err error) error {
+		if err == ErrCycle {
+			cycleAt = p.String()
+			return ipld.SkipNode
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cycleAt != "next/next" {
+		t.Errorf("cycle detected at %#v", cycleAt)
+	}
+
+	hc, _ := mh.Sum([]byte("c"), mh.SHA2_256, -1)
+	errStop := errors.New("stop")
+	err = Walk(ipld.Node{"missing": link(hc)}, loader, func(r, curr ipld.Node, p ipld.Path, err error) error {
+		if err != nil {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("expected error to be returned, got %v", err)
+	}
+}
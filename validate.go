@@ -0,0 +1,164 @@
+package ipld
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+)
+
+// Validator is implemented by Go types which check their own consistency.
+// Validate calls IPLDValidate on every Validator found in a value, and
+// Unmarshal does so after decoding a node.
+//
+// IPLDValidate should return a *ValidationError to report failures on
+// specific paths, relative to the validated value. Any other error is
+// reported at the path of the value itself.
+type Validator interface {
+	IPLDValidate() error
+}
+
+// ValidationFailure describes a single rule violation.
+type ValidationFailure struct {
+	Path   string // path of the invalid value
+	Reason string // human readable description
+}
+
+// ValidationError lists all the validation failures found in a value.
+type ValidationError struct {
+	Failures []ValidationFailure
+}
+
+// Add records a failure at the given path.
+func (e *ValidationError) Add(path, reason string) {
+	e.Failures = append(e.Failures, ValidationFailure{Path: path, Reason: reason})
+}
+
+// Addf records a failure at the given path, formatting the reason like
+// fmt.Sprintf.
+func (e *ValidationError) Addf(path, format string, args ...interface{}) {
+	e.Add(path, fmt.Sprintf(format, args...))
+}
+
+// Err returns e if it contains failures, nil otherwise. It is meant to be
+// returned from IPLDValidate.
+func (e *ValidationError) Err() error {
+	if e == nil || len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString("ipld: validation failed:")
+	for _, f := range e.Failures {
+		p := f.Path
+		if p == "" {
+			p = "/"
+		}
+		fmt.Fprintf(&buf, "\n  %s: %s", p, f.Reason)
+	}
+	return buf.String()
+}
+
+// merge adds the failures of err, found in a value at prefix.
+func (e *ValidationError) merge(prefix string, err error) {
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		e.Add(prefix, err.Error())
+		return
+	}
+	for _, f := range verr.Failures {
+		e.Add(path.Join(prefix, f.Path), f.Reason)
+	}
+}
+
+// Validate calls IPLDValidate on v and on all the values it contains
+// which implement Validator. Paths are built from the node keys the
+// values are marshalled to. It returns a *ValidationError listing all the
+// failures, or nil.
+func Validate(v interface{}) error {
+	var verr ValidationError
+	validateValue(reflect.ValueOf(v), "", &verr, map[uintptr]bool{})
+	return verr.Err()
+}
+
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+func validateValue(v reflect.Value, p string, verr *ValidationError, seen map[uintptr]bool) {
+	if !v.IsValid() {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		// seen holds the pointers on the current path only, so cycles
+		// end the recursion while values shared by several paths are
+		// validated at each of them.
+		if v.IsNil() || seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		validateValue(v.Elem(), p, verr, seen)
+		delete(seen, v.Pointer())
+		return
+	case reflect.Interface:
+		if !v.IsNil() {
+			validateValue(v.Elem(), p, verr, seen)
+		}
+		return
+	}
+
+	// validators are called on the concrete values only, so that methods
+	// declared on pointers are not called twice.
+	// values of unexported embedded structs cannot be used.
+	if !v.CanInterface() {
+		validateChildren(v, p, verr, seen)
+		return
+	}
+	if !v.CanAddr() && reflect.PtrTo(v.Type()).Implements(validatorType) {
+		cp := reflect.New(v.Type())
+		cp.Elem().Set(v)
+		v = cp.Elem()
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(validatorType) {
+		if err := v.Addr().Interface().(Validator).IPLDValidate(); err != nil {
+			verr.merge(p, err)
+		}
+	} else if v.Type().Implements(validatorType) {
+		if err := v.Interface().(Validator).IPLDValidate(); err != nil {
+			verr.merge(p, err)
+		}
+	}
+	validateChildren(v, p, verr, seen)
+}
+
+func validateChildren(v reflect.Value, p string, verr *ValidationError, seen map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), path.Join(p, strconv.Itoa(i)), verr, seen)
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, k := range v.MapKeys() {
+			validateValue(v.MapIndex(k), path.Join(p, k.String()), verr, seen)
+		}
+
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			fv, ok := fieldByIndex(v, f.index, false)
+			if ok {
+				validateValue(fv, path.Join(p, f.name), verr, seen)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ipld
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testTree struct {
+	Entries []testEntry
+	Owner   *testEntry `ipld:",omitempty"`
+}
+
+type testEntry struct {
+	Name   string
+	Target Link
+}
+
+func (e testEntry) IPLDValidate() error {
+	var verr ValidationError
+	if e.Name == "" {
+		verr.Add("name", "name is required")
+	}
+	if !IsLink(Node(e.Target)) {
+		verr.Add("target", "target must be an mlink")
+	}
+	return verr.Err()
+}
+
+func (t *testTree) IPLDValidate() error {
+	if len(t.Entries) == 0 {
+		return errors.New("at least one entry is required")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	valid := &testTree{Entries: []testEntry{{Name: "a", Target: testLinkA}}}
+	if err := Validate(valid); err != nil {
+		t.Errorf("unexpected validation error: %s", err)
+	}
+	if err := Validate(*valid); err != nil {
+		t.Errorf("unexpected validation error on value: %s", err)
+	}
+
+	invalid := &testTree{
+		Entries: []testEntry{{Name: "a", Target: testLinkA}, {Target: testLinkB}},
+		Owner:   &testEntry{Name: "me"},
+	}
+	err := Validate(invalid)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := []ValidationFailure{
+		{"entries/1/name", "name is required"},
+		{"owner/target", "target must be an mlink"},
+	}
+	if !reflect.DeepEqual(verr.Failures, expected) {
+		t.Errorf("unexpected failures: %#v", verr.Failures)
+	}
+
+	err = Validate(&testTree{})
+	verr, ok = err.(*ValidationError)
+	if !ok || len(verr.Failures) != 1 || verr.Failures[0].Path != "" {
+		t.Errorf("unexpected error: %#v", err)
+	}
+}
+
+type testPair struct {
+	Left  *testEntry
+	Right *testEntry
+}
+
+func TestValidateShared(t *testing.T) {
+	e := &testEntry{Target: testLinkA}
+	err := Validate(&testPair{Left: e, Right: e})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := []ValidationFailure{
+		{"left/name", "name is required"},
+		{"right/name", "name is required"},
+	}
+	if !reflect.DeepEqual(verr.Failures, expected) {
+		t.Errorf("unexpected failures: %#v", verr.Failures)
+	}
+}
+
+func TestUnmarshalValidates(t *testing.T) {
+	n := Node{
+		"entries": []interface{}{
+			Node{"name": "a", "target": Node(testLinkA)},
+			Node{"target": Node(testLinkB)},
+		},
+	}
+
+	var tree testTree
+	err := Unmarshal(n, &tree)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if len(verr.Failures) != 1 || verr.Failures[0].Path != "entries/1/name" {
+		t.Errorf("unexpected failures: %#v", verr.Failures)
+	}
+}
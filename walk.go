@@ -2,30 +2,10 @@ package ipld
 
 import (
 	"errors"
-	"path"
+	"sort"
 	"strconv"
-	"strings"
 )
 
-const pathSep = "/"
-
-// Escape path component. The special characters ("@" and "\") are escaped to
-// allow mixing the path component with directives (starting with "@") in IPLD
-// data structure.
-func EscapePathComponent(comp string) string {
-	comp = strings.Replace(comp, "\\", "\\\\", -1)
-	comp = strings.Replace(comp, "@", "\\@", -1)
-	return comp
-}
-
-// Unescape path component from the IPLD data structure. Special characters are
-// unescaped. See also EscapePathComponent function.
-func UnescapePathComponent(comp string) string {
-	comp = strings.Replace(comp, "\\@", "@", -1)
-	comp = strings.Replace(comp, "\\\\", "\\", -1)
-	return comp
-}
-
 // SkipNode is a special value used with Walk and WalkFunc.
 // If a WalkFunc returns SkipNode, the walk skips the curr
 // node and its children. It behaves like file/filepath.SkipDir
@@ -46,14 +26,16 @@ var SkipNode = errors.New("skip node from Walk")
 // SkipNode error, the children of curr are skipped. All other
 // errors halt processing early. In this respect, it behaves
 // just like file/filepath.WalkFunc
-type WalkFunc func(root, curr Node, path string, err error) error
+type WalkFunc func(root, curr Node, path Path, err error) error
 
 // Walk traverses the given root node and all its children, calling
 // WalkFunc with every Node visited, including root. All errors
 // that arise while visiting nodes are passed to given WalkFunc.
-// The order in which children are visited is not deterministic.
-// Walk traverses sequences as well, which is to mean the nodes
-// below will be visted as "foo/0", "foo/1", and "foo/3":
+// The order in which children are visited is not deterministic, see
+// WalkOrdered for a reproducible traversal.
+// Walk traverses sequences of any type as well, and maps with string
+// keys like map[string]Link (see AsMap and AsList), which is to mean the
+// nodes below will be visted as "foo/0", "foo/1", and "foo/3":
 //
 //   { "foo": [
 //     {"a":"aaa"}, // visited as foo/0
@@ -61,28 +43,81 @@ type WalkFunc func(root, curr Node, path string, err error) error
 //     {"c":"ccc"}, // visited as foo/2
 //   ]}
 //
+// Map keys containing "/" are visited as well, as a single path component
+// (written "a\/b" in the string form of the path, see Path). Empty keys and
+// keys starting with "@", which are directives, are not visited.
+//
+// Maps implementing Map, such as PersistentNode, are traversed too, and
+// passed to WalkFunc as a Node holding the same entries.
+//
 // Note Walk is purely local and does not traverse Links. For a
 // version of Walk that does traverse links, see the ipld/traverse
 // package.
 func Walk(root Node, walkFn WalkFunc) error {
-	return walk(root, root, "", walkFn)
+	return walk(root, root, nil, walkFn, nil)
+}
+
+// KeyLess reports whether the key a sorts before the key b. It sets the
+// order in which WalkOrdered and Transform visit the children of maps.
+type KeyLess func(a, b string) bool
+
+// CanonicalKeyLess is the canonical order of keys: shorter keys sort
+// first, keys of the same length sort bytewise. This is the order of map
+// keys in canonical CBOR.
+func CanonicalKeyLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+type keySorter struct {
+	keys []string
+	less KeyLess
+}
+
+func (s keySorter) Len() int           { return len(s.keys) }
+func (s keySorter) Swap(i, j int)      { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s keySorter) Less(i, j int) bool { return s.less(s.keys[i], s.keys[j]) }
+
+// mapKeys returns the keys of n, sorted with less unless it is nil.
+func mapKeys(n Node, less KeyLess) []string {
+	keys := make([]string, 0, len(n))
+	for k := range n {
+		keys = append(keys, k)
+	}
+	if less != nil {
+		sort.Sort(keySorter{keys, less})
+	}
+	return keys
+}
+
+// WalkOrdered is just like Walk, but visits the children of maps in the
+// order set by less, and the elements of lists in their order, so the
+// traversal is reproducible. If less is nil, CanonicalKeyLess is used.
+func WalkOrdered(root Node, less KeyLess, walkFn WalkFunc) error {
+	if less == nil {
+		less = CanonicalKeyLess
+	}
+	return walk(root, root, nil, walkFn, less)
 }
 
 // WalkFrom is just like Walk, but starts the Walk at given startFrom
 // sub-node. It is the equivalent of a regular Walk call which skips
 // all nodes which do not have startFrom as a prefix.
-func WalkFrom(root Node, startFrom string, walkFn WalkFunc) error {
-	start := GetPath(root, startFrom)
+func WalkFrom(root Node, startFrom Path, walkFn WalkFunc) error {
+	start := GetPathCmp(root, startFrom)
 	if start == nil {
-		return errors.New("no descendant at " + startFrom)
+		return errors.New("no descendant at " + startFrom.String())
 	}
-	return walk(root, start, startFrom, walkFn)
+	return walk(root, start, startFrom, walkFn, nil)
 }
 
-// walk is used to implement Walk.
-func walk(root Node, curr interface{}, npath string, walkFunc WalkFunc) error {
+// walk is used to implement Walk. Keys are visited in the order set by
+// less, or in map order if it is nil.
+func walk(root Node, curr interface{}, npath Path, walkFunc WalkFunc, less KeyLess) error {
 
-	if nc, ok := curr.(Node); ok { // it's a node!
+	if nc, ok := AsMap(curr); ok { // it's a node!
 		// first, call user's WalkFunc.
 		err := walkFunc(root, nc, npath, nil)
 		if err == SkipNode {
@@ -92,18 +127,14 @@ func walk(root Node, curr interface{}, npath string, walkFunc WalkFunc) error {
 		}
 
 		// then recurse.
-		for k, v := range nc {
+		for _, k := range mapKeys(nc, less) {
+			v := nc[k]
+
 			// Skip empty path components
 			if len(k) == 0 {
 				continue
 			}
 
-			// skip any keys which contain "/" in them.
-			// this is explicitly disallowed.
-			if strings.Contains(k, pathSep) {
-				continue
-			}
-
 			// skip keys starting with "@", it is reserved for directives
 			// It can be escaped using "\@" in which case, "@" is not the first
 			// character
@@ -112,16 +143,16 @@ func walk(root Node, curr interface{}, npath string, walkFunc WalkFunc) error {
 			}
 
 			k = UnescapePathComponent(k)
-			err := walk(root, v, path.Join(npath, k), walkFunc)
+			err := walk(root, v, npath.Append(k), walkFunc, less)
 			if err != nil {
 				return err
 			}
 		}
 
-	} else if sc, ok := curr.([]interface{}); ok { // it's a slice!
+	} else if sc, ok := AsList(curr); ok { // it's a slice!
 		for i, v := range sc {
 			k := strconv.Itoa(i)
-			err := walk(root, v, path.Join(npath, k), walkFunc)
+			err := walk(root, v, npath.Append(k), walkFunc, less)
 			if err != nil {
 				return err
 			}
@@ -135,14 +166,29 @@ func walk(root Node, curr interface{}, npath string, walkFunc WalkFunc) error {
 
 // GetPath gets a descendant of root, at npath. GetPath
 // uses the UNIX path abstraction: components of a
-// path are delimited with "/". The path MUST start with "/".
+// path are delimited with "/", and "\" and "/" within a
+// component are escaped as "\\" and "\/" (see ParsePath).
+//
+// Note GetPath is purely local and stops at links, returning the link
+// itself. To resolve paths across links, see traverse.Resolver.
 func GetPath(root interface{}, path_ string) interface{} {
-	path_ = path.Clean(path_)[1:] // skip root /
-	return GetPathCmp(root, strings.Split(path_, pathSep))
+	return GetPathCmp(root, ParsePath(path_))
 }
 
 // GetPathCmp gets a descendant of root, at npath.
-func GetPathCmp(root interface{}, npath []string) interface{} {
+func GetPathCmp(root interface{}, npath Path) interface{} {
+	return getPath(root, npath, false)
+}
+
+// GetRawPath gets a descendant of root, at npath, where the map keys of
+// npath are the keys as stored in the data rather than unescaped user keys.
+// Unlike GetPathCmp, it reaches directives: Path{"@type"} is the directive
+// "@type", and Path{`\@type`} the user key "@type".
+func GetRawPath(root interface{}, npath Path) interface{} {
+	return getPath(root, npath, true)
+}
+
+func getPath(root interface{}, npath Path, raw bool) interface{} {
 	if len(npath) == 0 {
 		return root // we're done.
 	}
@@ -151,12 +197,22 @@ func GetPathCmp(root interface{}, npath []string) interface{} {
 	}
 
 	k := npath[0]
-	if vn, ok := root.(Node); ok {
+	if vm, ok := root.(Map); ok {
+		// if map, look the key up without copying the map
+		if !raw {
+			k = EscapePathComponent(k)
+		}
+		v, _ := vm.Lookup(k)
+		return getPath(v, npath[1:], raw)
+
+	} else if vn, ok := AsMap(root); ok {
 		// if node, recurse
-		k = EscapePathComponent(k)
-		return GetPathCmp(vn[k], npath[1:])
+		if !raw {
+			k = EscapePathComponent(k)
+		}
+		return getPath(vn[k], npath[1:], raw)
 
-	} else if vs, ok := root.([]interface{}); ok {
+	} else if vs, ok := AsList(root); ok {
 		// if slice, use key as an int offset
 		i, err := strconv.Atoi(k)
 		if err != nil {
@@ -166,7 +222,7 @@ func GetPathCmp(root interface{}, npath []string) interface{} {
 			return nil
 		}
 
-		return GetPathCmp(vs[i], npath[1:])
+		return getPath(vs[i], npath[1:], raw)
 	}
 
 	return nil // cannot keep walking...
@@ -0,0 +1,86 @@
+package ipld
+
+import (
+	"reflect"
+	"testing"
+)
+
+var orderNode = Node{
+	"bb": Node{"y": 1, "x": 2},
+	"a":  []interface{}{Node{"c": 1}, Node{"d": 1}},
+	"ccc": Node{
+		"@type": "ignored",
+		"e":     Node{},
+	},
+}
+
+func TestWalkOrdered(t *testing.T) {
+	var paths []string
+	err := WalkOrdered(orderNode, nil, func(root, curr Node, path Path, err error) error {
+		paths = append(paths, path.String())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"", "a/0", "a/1", "bb", "ccc", "ccc/e"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	paths = nil
+	reverse := func(a, b string) bool { return a > b }
+	err = WalkOrdered(orderNode, reverse, func(root, curr Node, path Path, err error) error {
+		paths = append(paths, path.String())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"", "ccc", "ccc/e", "bb", "a/0", "a/1"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
+
+func TestTransformOrder(t *testing.T) {
+	var calls []Path
+	res, err := Transform(orderNode, func(root, curr Node, path Path, err error) (Node, error) {
+		calls = append(calls, path)
+		return nil, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, orderNode) {
+		t.Errorf("expected %#v, got %#v", orderNode, res)
+	}
+
+	// paths are not overwritten by the ones of the following nodes
+	var paths []string
+	for _, p := range calls {
+		paths = append(paths, p.String())
+	}
+	expected := []string{"", "a/0", "a/1", "bb", "ccc", "ccc/e"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
+
+func TestCanonicalKeyLess(t *testing.T) {
+	cases := []struct {
+		a, b string
+		less bool
+	}{
+		{"a", "b", true},
+		{"b", "a", false},
+		{"z", "aa", true},
+		{"aa", "z", false},
+		{"a", "a", false},
+	}
+	for _, c := range cases {
+		if CanonicalKeyLess(c.a, c.b) != c.less {
+			t.Errorf("CanonicalKeyLess(%q, %q) != %v", c.a, c.b, c.less)
+		}
+	}
+}